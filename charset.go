@@ -0,0 +1,345 @@
+// -----------------------------------------------------------------------------
+// File     : charset.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains the character set support that allows gopherhole to read
+// XML that isn't encoded as UTF-8. UTF-16 input is recognized from its byte
+// order mark, or from the way that its XML declaration is encoded, and is
+// decoded before the XML decoder sees it. Single-byte encodings from the
+// Latin-1 family are decoded when the XML declaration names them.
+//
+// Example: <?xml version="1.0" encoding="ISO-8859-1"?>
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Package level variables
+var windows1252 = singleByteTable(map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+})
+var iso885915 = singleByteTable(map[byte]rune{
+	0xA4: '€', 0xA6: 'Š', 0xA8: 'š', 0xB4: 'Ž', 0xB8: 'ž', 0xBC: 'Œ', 0xBD: 'œ', 0xBE: 'Ÿ',
+})
+
+// Character sets by the names that an XML declaration may give them, in
+// lower case. Like web browsers, text labeled ISO-8859-1 is decoded as
+// windows-1252, which only differs in characters that ISO-8859-1 leaves as
+// control codes and that exports labeled ISO-8859-1 commonly contain anyway.
+var charsets = map[string]*[256]rune{
+	"iso-8859-1":   windows1252,
+	"iso8859-1":    windows1252,
+	"iso_8859-1":   windows1252,
+	"latin1":       windows1252,
+	"latin-1":      windows1252,
+	"l1":           windows1252,
+	"cp819":        windows1252,
+	"windows-1252": windows1252,
+	"cp1252":       windows1252,
+	"x-cp1252":     windows1252,
+	"iso-8859-15":  iso885915,
+	"iso8859-15":   iso885915,
+	"iso_8859-15":  iso885915,
+	"latin-9":      iso885915,
+	"latin9":       iso885915,
+}
+
+// -----------------------------------------------------------------------------
+// ENCODING DETECTION
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : newInputReader()
+// Input        : r - A reader supplying XML data in any supported encoding
+// Output       :
+// A reader supplying the same XML data, in which UTF-16 has been decoded to
+// UTF-8 and any byte order mark has been removed
+//
+// Side Effects : The first bytes of the input are read ahead of time
+//
+// Abstract :
+// This function looks at the start of the input to recognize its encoding, as
+// described in Appendix F of the XML specification. A byte order mark names
+// UTF-8 or UTF-16 directly. Without one, UTF-16 is recognized by the way that
+// the '<?' at the start of the XML declaration is encoded. Any other input is
+// left to the XML decoder, which decodes it as UTF-8 or as the encoding that
+// its declaration names.
+// -----------------------------------------------------------------------------
+func newInputReader(r io.Reader) io.Reader {
+
+	buffered := bufio.NewReader(r)
+	start, _ := buffered.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(start, []byte{0xEF, 0xBB, 0xBF}):
+		buffered.Discard(3)
+		return buffered
+	case bytes.HasPrefix(start, []byte{0xFE, 0xFF}):
+		buffered.Discard(2)
+		return &utf16Reader{r: buffered, bigEndian: true}
+	case bytes.HasPrefix(start, []byte{0xFF, 0xFE}):
+		buffered.Discard(2)
+		return &utf16Reader{r: buffered}
+	case bytes.Equal(start, []byte{0x00, '<', 0x00, '?'}):
+		return &utf16Reader{r: buffered, bigEndian: true}
+	case bytes.Equal(start, []byte{'<', 0x00, '?', 0x00}):
+		return &utf16Reader{r: buffered}
+	}
+
+	return buffered
+}
+
+// -----------------------------------------------------------------------------
+// Function     : charsetReader()
+// Input        :
+// charset - A string representing the encoding named by an XML declaration
+// input - A reader supplying the XML data that follows the declaration
+//
+// Output       :
+// reader - A reader supplying the XML data decoded to UTF-8
+// err - An error if the encoding isn't supported
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the XML decoder's CharsetReader, which the decoder calls
+// when a declaration names an encoding other than UTF-8. Encodings that are
+// the same as UTF-8 for the characters they contain, and UTF-16, which was
+// decoded as the input was opened, are read as they are. Encodings from the
+// Latin-1 family are decoded a byte at a time.
+// -----------------------------------------------------------------------------
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+
+	name := strings.ToLower(strings.TrimSpace(charset))
+
+	switch name {
+	case "us-ascii", "ascii", "utf-16", "utf-16le", "utf-16be", "utf16":
+		return input, nil
+	}
+
+	table, ok := charsets[name]
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding '%s', expected UTF-8, UTF-16, ISO-8859-1, ISO-8859-15 or windows-1252", charset)
+	}
+
+	return &singleByteReader{r: input, table: table}, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// DECODERS
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : singleByteReader
+//
+// Abstract :
+// A singleByteReader decodes text in which every byte is one character,
+// using a table that gives the character for each byte, and supplies it as
+// UTF-8.
+// -----------------------------------------------------------------------------
+type singleByteReader struct {
+	r       io.Reader    // The encoded input
+	table   *[256]rune   // The character for each byte
+	chunk   [1024]byte   // Encoded bytes read from the input
+	pending bytes.Buffer // Decoded bytes that haven't been read yet
+	err     error        // The error that ended the input, if any
+}
+
+// -----------------------------------------------------------------------------
+// Method       : singleByteReader.Read()
+// Input        : p - A slice to read decoded bytes into
+// Output       :
+// n - The number of bytes read into p
+// err - An error if the input couldn't be read, or io.EOF at its end
+//
+// Side Effects : The input is read as needed
+//
+// Abstract :
+// This method implements the io.Reader interface for singleByteReader.
+// -----------------------------------------------------------------------------
+func (s *singleByteReader) Read(p []byte) (int, error) {
+
+	for s.pending.Len() == 0 && s.err == nil {
+		n, err := s.r.Read(s.chunk[:])
+		s.err = err
+
+		for _, b := range s.chunk[:n] {
+			s.pending.WriteRune(s.table[b])
+		}
+	}
+
+	if s.pending.Len() == 0 {
+		return 0, s.err
+	}
+
+	return s.pending.Read(p)
+}
+
+// -----------------------------------------------------------------------------
+// Type     : utf16Reader
+//
+// Abstract :
+// A utf16Reader decodes UTF-16 text and supplies it as UTF-8. Characters
+// that are split across reads of the input, including surrogate pairs, are
+// held until they're complete. Unpaired surrogates and a trailing odd byte
+// are decoded as U+FFFD.
+// -----------------------------------------------------------------------------
+type utf16Reader struct {
+	r         io.Reader    // The encoded input
+	bigEndian bool         // Whether each code unit's high byte comes first
+	chunk     [1024]byte   // Encoded bytes read from the input
+	buffer    []byte       // Encoded bytes that haven't been decoded yet
+	pending   bytes.Buffer // Decoded bytes that haven't been read yet
+	err       error        // The error that ended the input, if any
+}
+
+// -----------------------------------------------------------------------------
+// Method       : utf16Reader.Read()
+// Input        : p - A slice to read decoded bytes into
+// Output       :
+// n - The number of bytes read into p
+// err - An error if the input couldn't be read, or io.EOF at its end
+//
+// Side Effects : The input is read as needed
+//
+// Abstract :
+// This method implements the io.Reader interface for utf16Reader.
+// -----------------------------------------------------------------------------
+func (u *utf16Reader) Read(p []byte) (int, error) {
+
+	for u.pending.Len() == 0 && u.err == nil {
+		n, err := u.r.Read(u.chunk[:])
+		u.buffer = append(u.buffer, u.chunk[:n]...)
+		u.err = err
+		u.decode()
+	}
+
+	if u.pending.Len() == 0 {
+		return 0, u.err
+	}
+
+	return u.pending.Read(p)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : utf16Reader.decode()
+// Input        : none
+// Output       : none
+// Side Effects : Complete characters are moved from buffer to pending
+//
+// Abstract :
+// This method decodes every complete character in the buffer. Once the input
+// has ended, whatever remains is decoded as well.
+// -----------------------------------------------------------------------------
+func (u *utf16Reader) decode() {
+
+	i := 0
+
+	for ; i+1 < len(u.buffer); i += 2 {
+		unit := u.unit(i)
+
+		if utf16.IsSurrogate(rune(unit)) && unit < 0xDC00 {
+
+			// Wait for the rest of a surrogate pair
+			if i+3 >= len(u.buffer) {
+				if u.err == nil {
+					break
+				}
+
+				u.pending.WriteRune(utf8.RuneError)
+				continue
+			}
+
+			r := utf16.DecodeRune(rune(unit), rune(u.unit(i+2)))
+
+			if r != utf8.RuneError {
+				u.pending.WriteRune(r)
+				i += 2
+				continue
+			}
+		}
+
+		if utf16.IsSurrogate(rune(unit)) {
+			u.pending.WriteRune(utf8.RuneError)
+			continue
+		}
+
+		u.pending.WriteRune(rune(unit))
+	}
+
+	u.buffer = u.buffer[i:]
+
+	if u.err != nil && len(u.buffer) > 0 {
+		u.pending.WriteRune(utf8.RuneError)
+		u.buffer = nil
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : utf16Reader.unit()
+// Input        : i - The offset within the buffer of a code unit
+// Output       : The code unit at the offset
+// Side Effects : none
+//
+// Abstract :
+// This method reads a code unit from the buffer in the reader's byte order.
+// -----------------------------------------------------------------------------
+func (u *utf16Reader) unit(i int) uint16 {
+
+	if u.bigEndian {
+		return uint16(u.buffer[i])<<8 | uint16(u.buffer[i+1])
+	}
+
+	return uint16(u.buffer[i+1])<<8 | uint16(u.buffer[i])
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// UTILITY
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : singleByteTable()
+// Input        :
+// differences - The characters that the encoding gives to bytes for which
+// it differs from ISO-8859-1
+//
+// Output       : A table giving the character for each byte
+// Side Effects : none
+//
+// Abstract :
+// This function builds the decoding table of a Latin-1 family encoding. Every
+// byte not listed is the character with the same number, as in ISO-8859-1.
+// -----------------------------------------------------------------------------
+func singleByteTable(differences map[byte]rune) *[256]rune {
+
+	table := &[256]rune{}
+
+	for i := range table {
+		table[i] = rune(i)
+	}
+
+	for b, r := range differences {
+		table[b] = r
+	}
+
+	return table
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,122 @@
+package gopherhole
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+// encodeUTF16 encodes a string as UTF-16 in the given byte order
+func encodeUTF16(s string, order binary.ByteOrder) []byte {
+
+	units := utf16.Encode([]rune(s))
+	encoded := make([]byte, 2*len(units))
+
+	for i, unit := range units {
+		order.PutUint16(encoded[2*i:], unit)
+	}
+
+	return encoded
+}
+
+func TestConvertEncodings(t *testing.T) {
+
+	config := `{"Patients": [{"name": "<Patients.Patient.Name>"}]}`
+	document := `<?xml version="1.0" encoding="UTF-16"?><Patients><Patient><Name>Zoë 🏥</Name></Patient></Patients>`
+
+	var tests = []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"utf-8 with a byte order mark", []byte("\xEF\xBB\xBF<Patients><Patient><Name>Zoë</Name></Patient></Patients>"), "Zoë"},
+		{"iso-8859-1", []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><Patients><Patient><Name>Zo\xEB \x92s</Name></Patient></Patients>"), "Zoë ’s"},
+		{"latin1", []byte("<?xml version='1.0' encoding='latin1'?><Patients><Patient><Name>Jos\xE9</Name></Patient></Patients>"), "José"},
+		{"windows-1252", []byte("<?xml version=\"1.0\" encoding=\"windows-1252\"?><Patients><Patient><Name>\x93Zo\xEB\x94 \x80</Name></Patient></Patients>"), "“Zoë” €"},
+		{"iso-8859-15", []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-15\"?><Patients><Patient><Name>\xA4 \xBD</Name></Patient></Patients>"), "€ œ"},
+		{"us-ascii", []byte("<?xml version=\"1.0\" encoding=\"US-ASCII\"?><Patients><Patient><Name>Zoe</Name></Patient></Patients>"), "Zoe"},
+		{"utf-16 little endian", append([]byte{0xFF, 0xFE}, encodeUTF16(document, binary.LittleEndian)...), "Zoë 🏥"},
+		{"utf-16 big endian", append([]byte{0xFE, 0xFF}, encodeUTF16(document, binary.BigEndian)...), "Zoë 🏥"},
+		{"utf-16 without a byte order mark", encodeUTF16(document, binary.LittleEndian), "Zoë 🏥"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converter, err := NewConverter(strings.NewReader(config))
+
+			if err != nil {
+				t.Fatalf("Got error %v creating the converter", err)
+			}
+
+			// Read a byte at a time so that characters are split across reads
+			var output bytes.Buffer
+			err = converter.Convert(iotest.OneByteReader(bytes.NewReader(test.input)), &output)
+
+			if err != nil {
+				t.Fatalf("Got error %v converting the input", err)
+			}
+
+			got := compactJSON(t, output.String())
+			want := `{"Patients":[{"name":"` + test.want + `"}]}`
+
+			if got != want {
+				t.Errorf("Got %s, wanted %s", got, want)
+			}
+		})
+	}
+}
+
+func TestConvertUnsupportedEncoding(t *testing.T) {
+
+	converter, err := NewConverter(strings.NewReader(`{"Patients": [{"name": "<Patients.Patient.Name>"}]}`))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	input := `<?xml version="1.0" encoding="EBCDIC"?><Patients><Patient><Name>John</Name></Patient></Patients>`
+	err = converter.Convert(strings.NewReader(input), io.Discard)
+
+	var syntaxError *SyntaxError
+
+	if !errors.As(err, &syntaxError) {
+		t.Fatalf("Got error %v, wanted a *SyntaxError", err)
+	}
+
+	if syntaxError.Line != 1 || syntaxError.Column != 1 || !strings.Contains(syntaxError.Message, "unsupported encoding 'EBCDIC'") {
+		t.Errorf("Got %v, wanted an unsupported encoding on line 1, column 1", err)
+	}
+}
+
+func TestUTF16ReaderInvalid(t *testing.T) {
+
+	var tests = []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"unpaired high surrogate", []byte{'a', 0x00, 0x3D, 0xD8, 'b', 0x00}, "a�b"},
+		{"unpaired low surrogate", []byte{0x00, 0xDC, 'b', 0x00}, "�b"},
+		{"trailing high surrogate", []byte{'a', 0x00, 0x3D, 0xD8}, "a�"},
+		{"odd byte", []byte{'a', 0x00, 'b'}, "a�"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := io.ReadAll(&utf16Reader{r: iotest.OneByteReader(bytes.NewReader(test.input))})
+
+			if err != nil {
+				t.Fatalf("Got error %v reading the input", err)
+			}
+
+			if string(got) != test.want {
+				t.Errorf("Got %q, wanted %q", got, test.want)
+			}
+		})
+	}
+}
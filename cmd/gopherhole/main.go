@@ -0,0 +1,1273 @@
+// -----------------------------------------------------------------------------
+// Application : gopherhole
+// Engineer    : Christian Westbrook
+// Abstract    :
+// This application converts an input XML file into JSON data based
+// on the requirements specified in an accompanying configuration file.
+//
+// Example Usage:
+// gopherhole             <- defaults to converting input.xml using config.json
+// gopherhole myxmlfile.xml                    <- defaults to using config.json
+// gopherhole myxmlfile.xml myconfigfile.json
+// gopherhole convert --input myxmlfile.xml --config myconfigfile.json --output out.json
+// curl https://example.com/export.xml | gopherhole convert --config myconfigfile.json -
+// gopherhole convert --config myconfigfile.json --output-dir out exports/*.xml
+// gopherhole validate --config myconfigfile.json
+// gopherhole lint --config myconfigfile.json sample.xml
+// gopherhole version
+//
+// In any case, JSON data is generated from the input XML file in a format
+// specified the input configuration file and is printed to standard output, or
+// written to the file named by --output. Banners, progress messages and errors
+// are printed to standard error so that the output can be piped into other
+// programs.
+// -----------------------------------------------------------------------------
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopherhole"
+)
+
+// ROADMAP
+// - Handle parent key alias' e.g. Patients -> patients
+
+// The version of gopherhole, which can be replaced at build time with
+// go build -ldflags "-X main.version=1.2.3" ./cmd/gopherhole
+var version = "0.1.0"
+
+// Exit codes
+const (
+	exitSuccess   = 0 // The command completed successfully
+	exitFailure   = 1 // The command failed for any other reason, e.g. the output couldn't be written
+	exitUsage     = 2 // The command was used incorrectly, e.g. with an unknown flag
+	exitConfig    = 3 // The config file couldn't be read or is invalid
+	exitInput     = 4 // An input XML file couldn't be read
+	exitSyntax    = 5 // An input XML file is malformed
+	exitTransform = 6 // A value couldn't be transformed or converted to its type
+	exitMismatch  = 7 // In strict mode or for a required value, the input XML doesn't match the config file
+)
+
+// The usage text printed by gopherhole help
+const usage = `Usage: gopherhole [command] [flags] [input.xml ...] [config.json]
+
+Commands:
+  convert   Convert an XML file into JSON (the default command)
+  validate  Check that a configuration file can be used for conversion
+  lint      Report how well a configuration file matches sample XML files
+  version   Print the version of gopherhole
+  help      Print this message
+
+Run 'gopherhole <command> --help' to see the flags of a command.
+`
+
+// -----------------------------------------------------------------------------
+// Function : main()
+// Input    : none
+//
+// Command-line Arguments :
+// An optional command, convert, validate, lint, version or help, followed by the
+// command's flags and arguments. Without a command, gopherhole converts.
+//
+// Output       : none
+// Side Effects : The process exits with the exit code of the command
+//
+// Abstract :
+// This function serves as the entry point to gopherhole. It runs the command
+// given on the command line and exits with the command's exit code.
+// -----------------------------------------------------------------------------
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// -----------------------------------------------------------------------------
+// Function     : run()
+// Input        :
+// args - The command-line arguments, not including the program name
+// stdin - A reader that supplies input files given as -
+// stdout - A writer that receives the command's output
+// stderr - A writer that receives usage text and error messages
+//
+// Output       : An integer representing the exit code of the command
+// Side Effects : The command is run
+//
+// Abstract :
+// This function picks out the command named by the first argument and hands
+// the remaining arguments to it. If the first argument doesn't name a command,
+// every argument is handed to convert so that gopherhole can still be run as
+// gopherhole input.xml config.json.
+// -----------------------------------------------------------------------------
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	command := "convert"
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "convert", "validate", "lint", "version", "help":
+			command = args[0]
+			args = args[1:]
+		case "-h", "-help", "--help":
+			fmt.Fprint(stdout, usage)
+			return exitSuccess
+		}
+	}
+
+	switch command {
+	case "validate":
+		return validate(args, stdin, stdout, stderr)
+	case "lint":
+		return lint(args, stdin, stdout, stderr)
+	case "version":
+		return printVersion(args, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return exitSuccess
+	}
+
+	return convert(args, stdin, stdout, stderr)
+}
+
+// -----------------------------------------------------------------------------
+// COMMANDS
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : convert()
+// Input        :
+// args - The command's flags, optionally followed by the paths of XML files
+// and a configuration file
+// stdin - A reader that supplies an input XML file or config file given as -
+// stdout - A writer that receives the converted JSON
+// stderr - A writer that receives the banner, progress messages, usage text
+// and error messages
+//
+// Output       : An integer representing the exit code of the command
+// Side Effects : Converted JSON is printed to stdout or written to a file
+//
+// Abstract :
+// This function takes in an XML file to be converted and a configuration file
+// that specifies the output and hands both to a gopherhole.Converter, which
+// generates JSON data from the given XML based on the specification. Files
+// can be given by flag or, as in earlier versions, by position.
+//
+// Any number of XML files can be converted at once, given by name or by glob
+// pattern. Their objects are merged into a single output, or, with
+// --output-dir, each file is converted into an output file of its own.
+//
+// Only the converted JSON is printed to stdout. An output file is only
+// replaced once the conversion has succeeded, see writeFileAtomically.
+//
+// Example Usage:
+// gopherhole convert                      <- converts input.xml using config.json
+// gopherhole convert myxmlfile.xml myconfigfile.json
+// gopherhole convert --input myxmlfile.xml --format ndjson --output out.ndjson
+// gopherhole convert --config myconfigfile.json --output-dir out "exports/*.xml"
+// cat myxmlfile.xml | gopherhole convert -
+// -----------------------------------------------------------------------------
+func convert(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	flags := newFlagSet("convert", "[input.xml ...] [config.json]", stderr)
+	configFilePath := flags.String("config", "config.json", "the configuration `file` that specifies the output JSON format, or - for stdin")
+	inputPaths := &stringList{}
+	flags.Var(inputPaths, "input", "an XML `file` or glob pattern to convert, or - for stdin, which may be given more than once (default input.xml)")
+	outputPath := flags.String("output", "", "the `file` to write the output to instead of stdout")
+	outputDir := flags.String("output-dir", "", "the `directory` to write a separate output file for each input to, named after the input")
+	format := flags.String("format", "json", "the output `format`, either json or ndjson")
+	quiet := flags.Bool("quiet", false, "don't print the banner or progress messages to stderr")
+	pretty := flags.Bool("pretty", false, "indent JSON output, which is the default")
+	compact := flags.Bool("compact", false, "write JSON output without line breaks or indentation")
+	stream := flags.Bool("stream", false, "write each object as soon as it has been converted, listing collections in input order")
+	collectionKey := flags.String("collection-key", "", "the `field` that names each object's collection in ndjson output")
+	strict := flags.Bool("strict", false, "fail when a symbol isn't filled or the XML contains elements that the config file doesn't refer to")
+	lenient := flags.Bool("lenient", false, "replace symbols that aren't filled with their default or null instead of leaving them in the output")
+	whitespace := flags.String("whitespace", "", "the whitespace `policy` for XML text, either preserve, trim or collapse, instead of keeping text as written and treating blank text as empty")
+	asOf := flags.String("as-of", "", "the `date` that elapsed time is measured up to instead of today, as 2006-01-02 or in RFC 3339 format")
+
+	positional, err := parseFlags(flags, args)
+
+	if err != nil {
+		return flagErrorCode(err)
+	}
+
+	// -------------------------------------------------------------------------
+	// CHECK ARGUMENTS
+	// -------------------------------------------------------------------------
+	patterns, err := inputPatterns(flags, positional, *inputPaths, configFilePath)
+
+	if err != nil {
+		return usageError(flags, "%v", err)
+	}
+
+	if *outputPath != "" && *outputDir != "" {
+		return usageError(flags, "--output and --output-dir can't be used together")
+	}
+
+	if *pretty && *compact {
+		return usageError(flags, "--pretty and --compact can't be used together")
+	}
+
+	if *strict && *lenient {
+		return usageError(flags, "--strict and --lenient can't be used together")
+	}
+
+	mode := gopherhole.ModeDefault
+
+	switch {
+	case *strict:
+		mode = gopherhole.ModeStrict
+	case *lenient:
+		mode = gopherhole.ModeLenient
+	}
+
+	var whitespacePolicy gopherhole.Whitespace
+
+	switch *whitespace {
+	case "":
+		whitespacePolicy = gopherhole.WhitespaceDefault
+	case "preserve":
+		whitespacePolicy = gopherhole.WhitespacePreserve
+	case "trim":
+		whitespacePolicy = gopherhole.WhitespaceTrim
+	case "collapse":
+		whitespacePolicy = gopherhole.WhitespaceCollapse
+	default:
+		return usageError(flags, "unknown whitespace policy '%s', expected preserve, trim or collapse", *whitespace)
+	}
+
+	var outputFormat gopherhole.Format
+	extension := ".json"
+
+	switch *format {
+	case "json":
+		outputFormat = gopherhole.FormatJSON
+
+		if *collectionKey != "" {
+			return usageError(flags, "--collection-key can only be used with --format ndjson")
+		}
+	case "ndjson":
+		outputFormat = gopherhole.FormatNDJSON
+		extension = ".ndjson"
+
+		if *pretty {
+			return usageError(flags, "ndjson output can't be indented")
+		}
+	default:
+		return usageError(flags, "unknown output format '%s', expected json or ndjson", *format)
+	}
+
+	var asOfTime time.Time
+
+	if *asOf != "" {
+		asOfTime, err = parseAsOf(*asOf)
+
+		if err != nil {
+			return usageError(flags, "invalid --as-of date '%s', expected 2006-01-02 or RFC 3339 format", *asOf)
+		}
+	}
+
+	inputXMLPaths, err := expandInputs(patterns)
+
+	if err != nil {
+		return reportError(stderr, err)
+	}
+
+	// A streamed JSON document closes each collection's list as soon as
+	// another collection begins, so the collections of several inputs can't
+	// be merged into one
+	if *stream && len(inputXMLPaths) > 1 && *outputDir == "" && outputFormat == gopherhole.FormatJSON {
+		return usageError(flags, "--stream can't merge several inputs into one JSON document, use --output-dir or --format ndjson")
+	}
+	// -------------------------------------------------------------------------
+
+	if !*quiet {
+		// Introduce the application
+		intro(stderr)
+
+		fmt.Fprintln(stderr, "Processing", strings.Join(inputXMLPaths, ", "), "using", *configFilePath)
+		fmt.Fprintln(stderr)
+	}
+
+	// -------------------------------------------------------------------------
+	// OPEN CONFIG FILE
+	// -------------------------------------------------------------------------
+	configFile, err := openFile(*configFilePath, stdin)
+
+	if err != nil {
+		return reportError(stderr, &gopherhole.ConfigError{Err: fmt.Errorf("error opening the config file: %w", err)})
+	}
+	defer configFile.Close()
+
+	converter, err := gopherhole.NewConverter(configFile)
+
+	if err != nil {
+		return reportError(stderr, err)
+	}
+
+	converter.AsOf = asOfTime
+	converter.Stream = *stream
+	converter.Format = outputFormat
+	converter.Compact = *compact
+	converter.CollectionKey = *collectionKey
+	converter.Mode = mode
+	converter.Whitespace = whitespacePolicy
+	// -------------------------------------------------------------------------
+
+	// -------------------------------------------------------------------------
+	// CONVERSION TO JSON
+	// -------------------------------------------------------------------------
+	// Either convert each input into its own output file...
+	if *outputDir != "" {
+		outputPaths, err := outputPathsFor(inputXMLPaths, *outputDir, extension)
+
+		if err != nil {
+			return reportError(stderr, err)
+		}
+
+		for i, inputXMLPath := range inputXMLPaths {
+			err = writeFileAtomically(outputPaths[i], func(w io.Writer) error {
+				return convertInputs(converter, []string{inputXMLPath}, stdin, w)
+			})
+
+			if err != nil {
+				return reportError(stderr, err)
+			}
+
+			if !*quiet {
+				fmt.Fprintln(stderr, "Output for", inputXMLPath, "written to", outputPaths[i])
+			}
+		}
+
+		return exitSuccess
+	}
+
+	// ...or merge every input into a single output
+	write := func(w io.Writer) error {
+		return convertInputs(converter, inputXMLPaths, stdin, w)
+	}
+
+	if *outputPath == "" {
+		err = write(stdout)
+	} else {
+		err = writeFileAtomically(*outputPath, write)
+	}
+
+	if err != nil {
+		return reportError(stderr, err)
+	}
+
+	if !*quiet && *outputPath != "" {
+		fmt.Fprintln(stderr, "Output written to", *outputPath)
+	}
+	// -------------------------------------------------------------------------
+
+	return exitSuccess
+}
+
+// -----------------------------------------------------------------------------
+// Function     : validate()
+// Input        :
+// args - The command's flags, optionally followed by the path of a
+// configuration file
+// stdin - A reader that supplies the configuration file if it's given as -
+// stdout - A writer that receives the result of the validation
+// stderr - A writer that receives usage text and error messages
+//
+// Output       : An integer representing the exit code of the command
+// Side Effects : The result of the validation is printed
+//
+// Abstract :
+// This function checks a configuration file without converting any XML, and
+// prints every problem found along with the JSON path at which it was found.
+// The config file is invalid if any problem is found.
+//
+// Example Usage:
+// gopherhole validate myconfigfile.json
+// gopherhole validate --config myconfigfile.json --quiet
+// -----------------------------------------------------------------------------
+func validate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	flags := newFlagSet("validate", "[config.json]", stderr)
+	configFilePath := flags.String("config", "config.json", "the configuration `file` to validate, or - for stdin")
+	quiet := flags.Bool("quiet", false, "don't print anything if the config file is valid")
+
+	positional, err := parseFlags(flags, args)
+
+	if err != nil {
+		return flagErrorCode(err)
+	}
+
+	if len(positional) > 1 {
+		return usageError(flags, "too many arguments")
+	}
+
+	if len(positional) > 0 {
+		if givenFlags(flags)["config"] {
+			return usageError(flags, "the config file was given both by --config and by position")
+		}
+
+		*configFilePath = positional[0]
+	}
+
+	configFile, err := openFile(*configFilePath, stdin)
+
+	if err != nil {
+		return reportError(stderr, &gopherhole.ConfigError{Err: fmt.Errorf("error opening the config file: %w", err)})
+	}
+	defer configFile.Close()
+
+	diagnostics, err := gopherhole.ValidateConfig(configFile)
+
+	if err != nil {
+		return reportError(stderr, err)
+	}
+
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintf(stderr, "%s: %s\n", *configFilePath, diagnostic)
+	}
+
+	if len(diagnostics) > 0 {
+		fmt.Fprintln(stderr, *configFilePath, "is invalid")
+		return exitConfig
+	}
+
+	if !*quiet {
+		fmt.Fprintln(stdout, *configFilePath, "is valid")
+	}
+
+	return exitSuccess
+}
+
+// -----------------------------------------------------------------------------
+// Function     : lint()
+// Input        :
+// args - The command's flags, optionally followed by the paths of sample XML
+// files and a configuration file
+// stdin - A reader that supplies a sample XML file or config file given as -
+// stdout - A writer that receives the coverage report
+// stderr - A writer that receives usage text and error messages
+//
+// Output       : An integer representing the exit code of the command
+// Side Effects : The coverage report is printed
+//
+// Abstract :
+// This function reads sample XML files with a configuration file without
+// converting them, and prints a report of the objects found in each
+// collection, the symbols that were and weren't matched, and the XML paths
+// that the configuration never refers to. Files are given as for convert.
+//
+// Example Usage:
+// gopherhole lint sample.xml myconfigfile.json
+// gopherhole lint --config myconfigfile.json "samples/*.xml"
+// -----------------------------------------------------------------------------
+func lint(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	flags := newFlagSet("lint", "[sample.xml ...] [config.json]", stderr)
+	configFilePath := flags.String("config", "config.json", "the configuration `file` to check, or - for stdin")
+	inputPaths := &stringList{}
+	flags.Var(inputPaths, "input", "a sample XML `file` or glob pattern, or - for stdin, which may be given more than once (default input.xml)")
+
+	positional, err := parseFlags(flags, args)
+
+	if err != nil {
+		return flagErrorCode(err)
+	}
+
+	patterns, err := inputPatterns(flags, positional, *inputPaths, configFilePath)
+
+	if err != nil {
+		return usageError(flags, "%v", err)
+	}
+
+	inputXMLPaths, err := expandInputs(patterns)
+
+	if err != nil {
+		return reportError(stderr, err)
+	}
+
+	configFile, err := openFile(*configFilePath, stdin)
+
+	if err != nil {
+		return reportError(stderr, &gopherhole.ConfigError{Err: fmt.Errorf("error opening the config file: %w", err)})
+	}
+	defer configFile.Close()
+
+	converter, err := gopherhole.NewConverter(configFile)
+
+	if err != nil {
+		return reportError(stderr, err)
+	}
+
+	report := converter.NewReport()
+
+	for _, inputPath := range inputXMLPaths {
+		err = lintInput(converter, report, inputPath, stdin)
+
+		if err != nil {
+			return reportError(stderr, err)
+		}
+	}
+
+	printReport(stdout, report)
+
+	return exitSuccess
+}
+
+// -----------------------------------------------------------------------------
+// Function     : printVersion()
+// Input        :
+// args - The command's flags, of which there are none besides --help
+// stdout - A writer that receives the version
+// stderr - A writer that receives usage text and error messages
+//
+// Output       : An integer representing the exit code of the command
+// Side Effects : The version of gopherhole is printed
+//
+// Abstract :
+// This function prints the version of gopherhole.
+// -----------------------------------------------------------------------------
+func printVersion(args []string, stdout io.Writer, stderr io.Writer) int {
+
+	flags := newFlagSet("version", "", stderr)
+	positional, err := parseFlags(flags, args)
+
+	if err != nil {
+		return flagErrorCode(err)
+	}
+
+	if len(positional) > 0 {
+		return usageError(flags, "too many arguments")
+	}
+
+	fmt.Fprintln(stdout, "gopherhole", version)
+
+	return exitSuccess
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// ERRORS
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : reportError()
+// Input        :
+// stderr - A writer that receives error messages
+// err - The error that stopped the command
+//
+// Output       : An integer representing the exit code for the error
+// Side Effects : The error is printed
+//
+// Abstract :
+// This function reports an error and picks the exit code for it, so that
+// scripts can tell a bad config file apart from bad input XML.
+// -----------------------------------------------------------------------------
+func reportError(stderr io.Writer, err error) int {
+
+	fmt.Fprintln(stderr, err)
+
+	var configError *gopherhole.ConfigError
+	var inputError *gopherhole.InputError
+	var syntaxError *gopherhole.SyntaxError
+	var transformError *gopherhole.TransformError
+	var missingValueError *gopherhole.MissingValueError
+	var unmappedElementError *gopherhole.UnmappedElementError
+
+	switch {
+	case errors.As(err, &configError):
+		return exitConfig
+	case errors.As(err, &inputError):
+		return exitInput
+	case errors.As(err, &syntaxError):
+		return exitSyntax
+	case errors.As(err, &transformError):
+		return exitTransform
+	case errors.As(err, &missingValueError), errors.As(err, &unmappedElementError):
+		return exitMismatch
+	}
+
+	return exitFailure
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// FLAGS
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : stringList
+//
+// Abstract :
+// A stringList is a flag that can be given more than once, collecting each of
+// its values in order.
+//
+// Example: --input a.xml --input b.xml gives ['a.xml', 'b.xml']
+// -----------------------------------------------------------------------------
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : newFlagSet()
+// Input        :
+// command - The name of the command that the flags belong to
+// arguments - A description of the command's positional arguments
+// stderr - A writer that receives usage text and error messages
+//
+// Output       : A set of flags that prints the command's usage text on error
+// Side Effects : none
+//
+// Abstract :
+// This function creates an empty set of flags for a command. Parsing the
+// flags returns an error rather than exiting, so that commands decide on
+// their own exit codes.
+// -----------------------------------------------------------------------------
+func newFlagSet(command string, arguments string, stderr io.Writer) *flag.FlagSet {
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gopherhole", command, "[flags]", arguments)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Flags:")
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// -----------------------------------------------------------------------------
+// Function     : parseFlags()
+// Input        :
+// flags - The set of flags to parse
+// args - The command-line arguments to parse
+//
+// Output       :
+// positional - The arguments that aren't flags
+// err - An error describing why the arguments couldn't be parsed, which is
+// flag.ErrHelp if help was asked for
+//
+// Side Effects : The flags are set from the arguments
+//
+// Abstract :
+// This function parses a command's flags. Unlike flags.Parse, it allows flags
+// to follow positional arguments, so that both gopherhole convert --quiet
+// input.xml and gopherhole convert input.xml --quiet work.
+// -----------------------------------------------------------------------------
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+
+	positional := []string{}
+
+	for {
+		err := flags.Parse(args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Function     : givenFlags()
+// Input        : flags - A set of flags that has been parsed
+// Output       : A map from the name of each flag given on the command line to true
+// Side Effects : none
+//
+// Abstract :
+// This function records which flags were given on the command line, as
+// opposed to being left with their default values.
+// -----------------------------------------------------------------------------
+func givenFlags(flags *flag.FlagSet) map[string]bool {
+
+	given := make(map[string]bool)
+
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	return given
+}
+
+// -----------------------------------------------------------------------------
+// Function     : flagErrorCode()
+// Input        : err - An error returned by parseFlags
+// Output       : An integer representing the exit code for the error
+// Side Effects : none
+//
+// Abstract :
+// This function picks the exit code of a command whose flags couldn't be
+// parsed. Asking for help isn't a mistake, so it succeeds. The flag package
+// has already printed the error and the command's usage text.
+// -----------------------------------------------------------------------------
+func flagErrorCode(err error) int {
+
+	if errors.Is(err, flag.ErrHelp) {
+		return exitSuccess
+	}
+
+	return exitUsage
+}
+
+// -----------------------------------------------------------------------------
+// Function     : usageError()
+// Input        :
+// flags - The set of flags of the command that was misused
+// format - A format string describing the mistake, followed by its arguments
+//
+// Output       : An integer representing the exit code for misuse
+// Side Effects : The mistake and the command's usage text are printed
+//
+// Abstract :
+// This function reports a mistake in the way that a command was used.
+// -----------------------------------------------------------------------------
+func usageError(flags *flag.FlagSet, format string, a ...interface{}) int {
+
+	fmt.Fprintf(flags.Output(), format+"\n", a...)
+	flags.Usage()
+
+	return exitUsage
+}
+
+// -----------------------------------------------------------------------------
+// Function     : inputPatterns()
+// Input        :
+// flags - The set of flags of a command that reads input XML files
+// positional - The command's positional arguments
+// inputPaths - The values of the command's --input flag
+// configFilePath - The value of the command's --config flag
+//
+// Output       :
+// patterns - The paths and glob patterns of the input XML files
+// err - An error if both an input file and the config file are given as -
+//
+// Side Effects : The config file path is replaced if given by position
+//
+// Abstract :
+// This function gathers the input XML files of a command. Positional
+// arguments name input XML files, except that without --config the last of
+// them may name the config file instead. It does if its name ends in .json.
+// As in earlier versions of gopherhole, it also does when there are exactly
+// two, or when input files are also given with --input, unless it's an
+// existing .xml file. Without any input files, input.xml is used.
+//
+// Example: gopherhole input.xml settings.conf reads settings.conf as the
+// config file, while gopherhole first.xml second.xml converts both files
+// -----------------------------------------------------------------------------
+func inputPatterns(flags *flag.FlagSet, positional []string, inputPaths []string, configFilePath *string) ([]string, error) {
+
+	if len(positional) > 0 && !givenFlags(flags)["config"] {
+		last := positional[len(positional)-1]
+
+		byPosition := strings.EqualFold(filepath.Ext(last), ".json") ||
+			((len(inputPaths) > 0 || len(positional) == 2) && !isXMLFile(last))
+
+		if byPosition {
+			*configFilePath = last
+			positional = positional[:len(positional)-1]
+		}
+	}
+
+	patterns := append([]string{}, inputPaths...)
+	patterns = append(patterns, positional...)
+
+	if len(patterns) == 0 {
+		patterns = []string{"input.xml"}
+	}
+
+	// Standard input can only be read once
+	stdinReaders := 0
+
+	for _, path := range append(patterns, *configFilePath) {
+		if path == "-" {
+			stdinReaders++
+		}
+	}
+
+	if stdinReaders > 1 {
+		return nil, errors.New("only one of the input XML files and the config file can be read from stdin")
+	}
+
+	return patterns, nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : isXMLFile()
+// Input        : path - A string representing a positional argument
+// Output       : A boolean that is true if the path names an existing .xml file
+// Side Effects : The file system is queried
+//
+// Abstract :
+// This function recognizes a positional argument that can only be an input
+// XML file, so that it's never read as the config file.
+// -----------------------------------------------------------------------------
+func isXMLFile(path string) bool {
+
+	if !strings.EqualFold(filepath.Ext(path), ".xml") {
+		return false
+	}
+
+	info, err := os.Stat(path)
+
+	return err == nil && info.Mode().IsRegular()
+}
+
+// -----------------------------------------------------------------------------
+// Function     : parseAsOf()
+// Input        : s - A string representing a date or a date and time
+// Output       :
+// t - The time that the string represents
+// err - An error if the string isn't a date or an RFC 3339 date and time
+//
+// Side Effects : none
+//
+// Abstract :
+// This function reads the value of the --as-of flag, which may be a date such
+// as 2025-01-01, read in UTC, or a date and time in RFC 3339 format.
+// -----------------------------------------------------------------------------
+func parseAsOf(s string) (time.Time, error) {
+
+	t, err := time.Parse(time.DateOnly, s)
+
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// FILES
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : openFile()
+// Input        :
+// path - The path of a file to read, or - for standard input
+// stdin - A reader supplying standard input
+//
+// Output       :
+// file - A reader supplying the file's contents, which must be closed
+// err - An error describing why the file couldn't be opened
+//
+// Side Effects : The file is opened
+//
+// Abstract :
+// This function opens a file for reading, treating the path - as standard
+// input. Closing standard input's reader leaves standard input open.
+// -----------------------------------------------------------------------------
+func openFile(path string, stdin io.Reader) (io.ReadCloser, error) {
+
+	if path == "-" {
+		return io.NopCloser(stdin), nil
+	}
+
+	return os.Open(path)
+}
+
+// -----------------------------------------------------------------------------
+// Function     : expandInputs()
+// Input        : patterns - The paths or glob patterns of the input XML files
+// Output       :
+// paths - The paths of the input XML files
+// err - An error if a glob pattern is malformed, or a *gopherhole.InputError if
+// it matches no files
+//
+// Side Effects : none
+//
+// Abstract :
+// This function expands glob patterns such as exports/*.xml into the paths of
+// the files they match, in alphabetical order, so that patterns work even
+// where the shell doesn't expand them. Other paths, including -, are kept as
+// they are.
+// -----------------------------------------------------------------------------
+func expandInputs(patterns []string) ([]string, error) {
+
+	paths := []string{}
+
+	for _, pattern := range patterns {
+
+		if pattern == "-" || !strings.ContainsAny(pattern, "*?[") {
+			paths = append(paths, pattern)
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid input pattern %s: %w", pattern, err)
+		}
+
+		if len(matches) == 0 {
+			return nil, &gopherhole.InputError{Err: fmt.Errorf("no files match %s", pattern)}
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	return paths, nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : outputPathsFor()
+// Input        :
+// inputPaths - The paths of the input XML files
+// dir - The directory that the output files are written to
+// extension - The extension of the output files, e.g. .json
+//
+// Output       :
+// outputPaths - The path of the output file of each input XML file
+// err - An error if two inputs would be written to the same output file or the
+// directory can't be created
+//
+// Side Effects : The output directory is created if it doesn't exist
+//
+// Abstract :
+// This function names the output file of each input XML file after the input
+// file, e.g. exports/patients.xml is written to out/patients.json. Standard
+// input is written to stdin.json.
+// -----------------------------------------------------------------------------
+func outputPathsFor(inputPaths []string, dir string, extension string) ([]string, error) {
+
+	outputPaths := []string{}
+	inputsByOutput := make(map[string]string)
+
+	for _, inputPath := range inputPaths {
+		name := "stdin"
+
+		if inputPath != "-" {
+			name = strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+		}
+
+		outputPath := filepath.Join(dir, name+extension)
+		other, ok := inputsByOutput[outputPath]
+
+		if ok {
+			return nil, fmt.Errorf("the outputs of %s and %s would both be written to %s", other, inputPath, outputPath)
+		}
+
+		inputsByOutput[outputPath] = inputPath
+		outputPaths = append(outputPaths, outputPath)
+	}
+
+	err := os.MkdirAll(dir, 0755)
+
+	if err != nil {
+		return nil, fmt.Errorf("error creating the output directory: %w", err)
+	}
+
+	return outputPaths, nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : convertInputs()
+// Input        :
+// converter - The Converter to convert the input XML files with
+// inputPaths - The paths of the input XML files, where - is standard input
+// stdin - A reader supplying standard input
+// w - A writer that receives the converted JSON
+//
+// Output       : err - An error describing which input couldn't be converted and why
+// Side Effects : The converted JSON is written to w
+//
+// Abstract :
+// This function converts each input XML file in turn, merging their objects
+// into a single output. Only one input file is open at a time.
+// -----------------------------------------------------------------------------
+func convertInputs(converter *gopherhole.Converter, inputPaths []string, stdin io.Reader, w io.Writer) error {
+
+	output, err := converter.NewOutput(w)
+
+	if err != nil {
+		return err
+	}
+
+	for _, inputPath := range inputPaths {
+		err = convertInput(output, inputPath, stdin)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return output.Close()
+}
+
+// -----------------------------------------------------------------------------
+// Function     : convertInput()
+// Input        :
+// output - The output that the input XML file's objects are added to
+// inputPath - The path of the input XML file, where - is standard input
+// stdin - A reader supplying standard input
+//
+// Output       : err - An error describing why the input couldn't be converted
+// Side Effects : The input's objects are added to the output
+//
+// Abstract :
+// This function opens, converts and closes a single input XML file.
+// -----------------------------------------------------------------------------
+func convertInput(output *gopherhole.Output, inputPath string, stdin io.Reader) error {
+
+	xmlFile, err := openFile(inputPath, stdin)
+
+	if err != nil {
+		return &gopherhole.InputError{Err: err}
+	}
+	defer xmlFile.Close()
+
+	err = output.Convert(xmlFile)
+
+	if err != nil {
+		return fmt.Errorf("error converting %s: %w", inputPath, err)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : lintInput()
+// Input        :
+// converter - The Converter whose configuration is being checked
+// report - The coverage report that the sample is recorded in
+// inputPath - The path of the sample XML file, where - is standard input
+// stdin - A reader supplying standard input
+//
+// Output       : err - An error describing why the sample couldn't be read
+// Side Effects : The sample is recorded in the report
+//
+// Abstract :
+// This function opens, lints and closes a single sample XML file.
+// -----------------------------------------------------------------------------
+func lintInput(converter *gopherhole.Converter, report *gopherhole.Report, inputPath string, stdin io.Reader) error {
+
+	xmlFile, err := openFile(inputPath, stdin)
+
+	if err != nil {
+		return &gopherhole.InputError{Err: err}
+	}
+	defer xmlFile.Close()
+
+	err = converter.Lint(xmlFile, report)
+
+	if err != nil {
+		return fmt.Errorf("error linting %s: %w", inputPath, err)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : writeFileAtomically()
+// Input        :
+// path - The path of the file to write
+// write - A function that writes the file's contents to the given writer
+//
+// Output       : err - An error describing why the file couldn't be written
+// Side Effects : The file at the given path is created or replaced
+//
+// Abstract :
+// This function writes a file without ever leaving it half written. The
+// contents are written to a temporary file in the same directory, which then
+// replaces the file at the given path in a single rename. If anything goes
+// wrong, the temporary file is removed and any existing file is left as it
+// was. A replaced file keeps its permissions.
+// -----------------------------------------------------------------------------
+func writeFileAtomically(path string, write func(w io.Writer) error) (err error) {
+
+	mode := os.FileMode(0644)
+	info, err := os.Stat(path)
+
+	if err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+
+	if err != nil {
+		return fmt.Errorf("error creating the output file: %w", err)
+	}
+
+	// Clean up the temporary file unless it replaced the output file
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	err = write(temp)
+
+	if err != nil {
+		return err
+	}
+
+	err = temp.Chmod(mode)
+
+	if err == nil {
+		err = temp.Sync()
+	}
+
+	if err == nil {
+		err = temp.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error writing the output file: %w", err)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// UTILITY
+// -----------------------------------------------------------------------------
+// -----------------------------------------------------------------------------
+// Function     : printReport()
+// Input        :
+// w - A writer that receives the report
+// report - A coverage report to print
+//
+// Output       : none
+// Side Effects : The report is printed
+//
+// Abstract :
+// This function prints a coverage report as aligned sections of text, each
+// listed in alphabetical order.
+// -----------------------------------------------------------------------------
+func printReport(w io.Writer, report *gopherhole.Report) {
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Collections and the number of objects in each:")
+
+	for _, collection := range sortedKeys(report.Objects) {
+		fmt.Fprintf(tw, "  %s\t%d\n", collection, report.Objects[collection])
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Matched symbols and the number of objects that each matched:")
+
+	for _, symbol := range report.Matched() {
+		fmt.Fprintf(tw, "  %s\t%d\n", symbol, report.Symbols[symbol])
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Symbols that never matched:")
+
+	for _, symbol := range report.Unmatched() {
+		fmt.Fprintf(tw, "  %s\n", symbol)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "XML paths that the config file doesn't refer to and the number of times that each appeared:")
+
+	for _, path := range sortedKeys(report.Unreferenced) {
+		fmt.Fprintf(tw, "  %s\t%d\n", path, report.Unreferenced[path])
+	}
+
+	tw.Flush()
+}
+
+// -----------------------------------------------------------------------------
+// Function     : sortedKeys()
+// Input        : m - A map of counts
+// Output       : A list of the map's keys in alphabetical order
+// Side Effects : none
+//
+// Abstract :
+// This function lists the keys of a map in a stable order.
+// -----------------------------------------------------------------------------
+func sortedKeys(m map[string]int) []string {
+
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// -----------------------------------------------------------------------------
+// Function     : intro()
+// Input        : w - A writer that receives the introductory message
+// Output       : none
+// Side Effects : Prints an introductory message to the given writer
+//
+// Abstract :
+// This function introduces the application by printing a message to the screen
+// -----------------------------------------------------------------------------
+func intro(w io.Writer) {
+	// Introduction
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Welcome to the Gopher Hole v"+version+"!")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Throw your XML into the hole, and the Gophers will toss back JSON!")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "+--------------------+")
+	fmt.Fprintln(w, "| Was that XML raw?  |")
+	fmt.Fprintln(w, "+--------------------+")
+	fmt.Fprintln(w, "  \\")
+	fmt.Fprintln(w, "   \\")
+	fmt.Fprintln(w, "    \\")
+	fmt.Fprintln(w, "         ,_---~~~~~----._         ")
+	fmt.Fprintln(w, "  _,,_,*^____      _____``*g*\"*, ")
+	fmt.Fprintln(w, " / __/ /'     ^.  /      \\ ^@q   f ")
+	fmt.Fprintln(w, "[  @f | @))    |  | @))   l  0 _/  ")
+	fmt.Fprintln(w, " \\`/   \\~____ / __ \\_____/    \\   ")
+	fmt.Fprintln(w, " |           _l__l_           I   ")
+	fmt.Fprintln(w, " }          [______]           I  ")
+	fmt.Fprintln(w, " ]            | | |            |  ")
+	fmt.Fprintln(w, " ]             ~ ~             |  ")
+	fmt.Fprintln(w, " |                            |   ")
+	fmt.Fprintln(w, "  |                           |   ")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Developed by Christian Westbrook ")
+	fmt.Fprintln(w, "https://github.com/christian-westbrook/")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Artwork by belbomemo")
+	fmt.Fprintln(w, "https://gist.github.com/belbomemo")
+	fmt.Fprintln(w)
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{"Patients": [{"id": "<Patients.Patient.ID>", "age": "<Patients.Patient.DateOfBirth transform=yearsElapsed>"}]}`
+const testInput = `<Patients><Patient ID="1"><DateOfBirth>1985-07-15</DateOfBirth></Patient></Patients>`
+
+// writeTestFiles writes a config file and an input XML file into a temporary
+// directory and returns their paths
+func writeTestFiles(t *testing.T) (string, string) {
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	inputPath := filepath.Join(dir, "input.xml")
+
+	err := os.WriteFile(configPath, []byte(testConfig), 0644)
+
+	if err == nil {
+		err = os.WriteFile(inputPath, []byte(testInput), 0644)
+	}
+
+	if err != nil {
+		t.Fatalf("Got error %v writing the test files", err)
+	}
+
+	return configPath, inputPath
+}
+
+func TestRun(t *testing.T) {
+
+	configPath, inputPath := writeTestFiles(t)
+
+	// A config file can be given by position whatever its extension
+	otherConfigPath := filepath.Join(filepath.Dir(configPath), "config.conf")
+	err := os.WriteFile(otherConfigPath, []byte(testConfig), 0644)
+
+	// An input in an encoding that gopherhole can't read
+	ebcdicPath := filepath.Join(filepath.Dir(inputPath), "ebcdic.xml.in")
+
+	if err == nil {
+		err = os.WriteFile(ebcdicPath, []byte(`<?xml version="1.0" encoding="EBCDIC"?>`+testInput), 0644)
+	}
+
+	if err != nil {
+		t.Fatalf("Got error %v writing the test files", err)
+	}
+
+	var tests = []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{"help", []string{"help"}, exitSuccess, "Usage: gopherhole"},
+		{"help flag", []string{"--help"}, exitSuccess, "Usage: gopherhole"},
+		{"command help", []string{"convert", "--help"}, exitSuccess, ""},
+		{"version", []string{"version"}, exitSuccess, "gopherhole " + version + "\n"},
+		{"version with arguments", []string{"version", "extra"}, exitUsage, ""},
+		{"unknown flag", []string{"convert", "--bogus"}, exitUsage, ""},
+		{"unknown format", []string{"--format", "yaml", "--input", inputPath, "--config", configPath}, exitUsage, ""},
+		{"pretty and compact", []string{"--pretty", "--compact", "--input", inputPath, "--config", configPath}, exitUsage, ""},
+		{"collection key without ndjson", []string{"--collection-key", "c", "--input", inputPath, "--config", configPath}, exitUsage, ""},
+		{"strict and lenient", []string{"--strict", "--lenient", "--input", inputPath, "--config", configPath}, exitUsage, ""},
+		{"unknown whitespace", []string{"--whitespace", "squash", "--input", inputPath, "--config", configPath}, exitUsage, ""},
+		{"stream merged inputs", []string{"--stream", "--config", configPath, inputPath, inputPath}, exitUsage, ""},
+		{
+			"stream merged inputs as ndjson",
+			[]string{"--quiet", "--stream", "--format", "ndjson", "--as-of", "2025-02-01", "--config", configPath, inputPath, inputPath},
+			exitSuccess,
+			`{"id":1,"age":39}` + "\n" + `{"id":1,"age":39}` + "\n",
+		},
+		{"invalid as-of", []string{"--as-of", "yesterday", "--input", inputPath, "--config", configPath}, exitUsage, ""},
+		{"stdin given twice", []string{"--config", "-", "-"}, exitUsage, ""},
+		{"output and output-dir", []string{"--output", "a.json", "--output-dir", "out", inputPath}, exitUsage, ""},
+		{"pattern without matches", []string{"--config", configPath, inputPath + "*.missing"}, exitInput, ""},
+		{"missing input", []string{"--quiet", "--input", inputPath + ".missing", "--config", configPath}, exitInput, ""},
+		{"missing config", []string{"--quiet", "--input", inputPath, "--config", configPath + ".missing"}, exitConfig, ""},
+		{"unsupported encoding", []string{"--quiet", "--input", ebcdicPath, "--config", configPath}, exitSyntax, ""},
+		{"invalid config", []string{"--quiet", "--input", inputPath, "--config", inputPath}, exitConfig, ""},
+		{"malformed input", []string{"--quiet", "--input", configPath, "--config", configPath}, exitSyntax, ""},
+		{
+			"positional",
+			[]string{"--quiet", "--compact", "--as-of", "2025-02-01", inputPath, configPath},
+			exitSuccess,
+			`{"Patients":[{"id":1,"age":39}]}` + "\n",
+		},
+		{
+			"strict",
+			[]string{"--quiet", "--compact", "--strict", "--as-of", "2025-02-01", inputPath, configPath},
+			exitSuccess,
+			`{"Patients":[{"id":1,"age":39}]}` + "\n",
+		},
+		{
+			"flags after arguments",
+			[]string{"convert", inputPath, "--config", configPath, "--quiet", "--format", "ndjson", "--collection-key", "collection", "--as-of", "2025-02-01T00:00:00Z"},
+			exitSuccess,
+			`{"collection":"Patients","id":1,"age":39}` + "\n",
+		},
+		{
+			"stdin",
+			[]string{"--quiet", "--compact", "--as-of", "2025-02-01", "-", configPath},
+			exitSuccess,
+			`{"Patients":[{"id":1,"age":39}]}` + "\n",
+		},
+		{
+			"positional config without .json",
+			[]string{"--quiet", "--compact", "--as-of", "2025-02-01", inputPath, otherConfigPath},
+			exitSuccess,
+			`{"Patients":[{"id":1,"age":39}]}` + "\n",
+		},
+		{
+			"input flag and positional config",
+			[]string{"--quiet", "--compact", "--as-of", "2025-02-01", "--input", inputPath, configPath},
+			exitSuccess,
+			`{"Patients":[{"id":1,"age":39}]}` + "\n",
+		},
+		{"lint with input flag and positional config", []string{"lint", "--input", inputPath, configPath}, exitSuccess, ""},
+		{
+			"merged inputs",
+			[]string{"--quiet", "--compact", "--as-of", "2025-02-01", "--config", configPath, "--input", inputPath, "-", inputPath},
+			exitSuccess,
+			`{"Patients":[{"id":1,"age":39},{"id":1,"age":39},{"id":1,"age":39}]}` + "\n",
+		},
+		{
+			"glob",
+			[]string{"--quiet", "--compact", "--as-of", "2025-02-01", "--config", configPath, filepath.Join(filepath.Dir(inputPath), "*.xml")},
+			exitSuccess,
+			`{"Patients":[{"id":1,"age":39}]}` + "\n",
+		},
+		{"validate", []string{"validate", "--config", configPath}, exitSuccess, configPath + " is valid\n"},
+		{"validate quietly", []string{"validate", "--quiet", configPath}, exitSuccess, ""},
+		{"validate invalid config", []string{"validate", inputPath}, exitConfig, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(test.args, strings.NewReader(testInput), &stdout, &stderr)
+
+			if code != test.wantCode {
+				t.Fatalf("Got exit code %d, wanted %d, with stderr %s", code, test.wantCode, stderr.String())
+			}
+
+			if test.wantCode != exitSuccess || test.wantOut == "" {
+				return
+			}
+
+			if strings.HasPrefix(test.wantOut, "Usage") {
+				if !strings.HasPrefix(stdout.String(), test.wantOut) {
+					t.Errorf("Got %s, wanted usage text", stdout.String())
+				}
+
+				return
+			}
+
+			if stdout.String() != test.wantOut {
+				t.Errorf("Got %s, wanted %s", stdout.String(), test.wantOut)
+			}
+		})
+	}
+}
+
+func TestRunPositionalInputs(t *testing.T) {
+
+	configPath, inputPath := writeTestFiles(t)
+	otherInputPath := filepath.Join(filepath.Dir(inputPath), "other.xml")
+
+	if err := os.WriteFile(otherInputPath, []byte(testInput), 0644); err != nil {
+		t.Fatalf("Got error %v writing the test files", err)
+	}
+
+	// The default config file is read from the working directory
+	workingDir, err := os.Getwd()
+
+	if err != nil {
+		t.Fatalf("Got error %v finding the working directory", err)
+	}
+
+	if err := os.Chdir(filepath.Dir(configPath)); err != nil {
+		t.Fatalf("Got error %v changing the working directory", err)
+	}
+
+	t.Cleanup(func() { os.Chdir(workingDir) })
+
+	var tests = []struct {
+		name string
+		args []string
+	}{
+		{"two xml files", []string{inputPath, otherInputPath}},
+		{"input flag and xml file", []string{"--input", inputPath, otherInputPath}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"--quiet", "--compact", "--as-of", "2025-02-01"}, test.args...)
+			code := run(args, strings.NewReader(""), &stdout, &stderr)
+
+			if code != exitSuccess {
+				t.Fatalf("Got exit code %d, wanted %d, with stderr %s", code, exitSuccess, stderr.String())
+			}
+
+			want := `{"Patients":[{"id":1,"age":39},{"id":1,"age":39}]}` + "\n"
+
+			if stdout.String() != want {
+				t.Errorf("Got %s, wanted %s", stdout.String(), want)
+			}
+		})
+	}
+}
+
+func TestRunOutputFile(t *testing.T) {
+
+	configPath, inputPath := writeTestFiles(t)
+	outputPath := filepath.Join(t.TempDir(), "output.json")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--input", inputPath, "--config", configPath, "--output", outputPath, "--compact", "--as-of", "2025-02-01", "--quiet"}, strings.NewReader(""), &stdout, &stderr)
+
+	if code != exitSuccess {
+		t.Fatalf("Got exit code %d, wanted %d, with stderr %s", code, exitSuccess, stderr.String())
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("Got %s on stdout, wanted nothing", stdout.String())
+	}
+
+	got, err := os.ReadFile(outputPath)
+
+	if err != nil {
+		t.Fatalf("Got error %v reading the output file", err)
+	}
+
+	want := `{"Patients":[{"id":1,"age":39}]}` + "\n"
+
+	if string(got) != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestRunBannerOnStderr(t *testing.T) {
+
+	configPath, inputPath := writeTestFiles(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--input", inputPath, "--config", configPath, "--as-of", "2025-02-01"}, strings.NewReader(""), &stdout, &stderr)
+
+	if code != exitSuccess {
+		t.Fatalf("Got exit code %d, wanted %d, with stderr %s", code, exitSuccess, stderr.String())
+	}
+
+	// Everything printed to stdout should be the converted JSON
+	var output map[string]interface{}
+	err := json.Unmarshal(stdout.Bytes(), &output)
+
+	if err != nil {
+		t.Errorf("Got error %v parsing stdout %s", err, stdout.String())
+	}
+
+	if !strings.Contains(stderr.String(), "Welcome to the Gopher Hole") {
+		t.Errorf("Got %s on stderr, wanted the banner", stderr.String())
+	}
+}
+
+func TestRunOutputFileFailure(t *testing.T) {
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	inputPath := filepath.Join(dir, "input.xml")
+	outputPath := filepath.Join(dir, "output.json")
+
+	// A collection that appears twice can't be streamed, so the conversion
+	// fails after it has started writing output
+	files := map[string]string{
+		configPath: `{"Patients": [{"id": "<Patients.Patient.ID>"}], "Doctors": [{"id": "<Doctors.Doctor.ID>"}]}`,
+		inputPath:  `<Patients><Patient ID="1"></Patient></Patients><Doctors></Doctors><Patients></Patients>`,
+		outputPath: "previous output\n",
+	}
+
+	for path, contents := range files {
+		err := os.WriteFile(path, []byte(contents), 0644)
+
+		if err != nil {
+			t.Fatalf("Got error %v writing the test files", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--quiet", "--stream", "--input", inputPath, "--config", configPath, "--output", outputPath}, strings.NewReader(""), &stdout, &stderr)
+
+	if code != exitFailure {
+		t.Fatalf("Got exit code %d, wanted %d", code, exitFailure)
+	}
+
+	got, err := os.ReadFile(outputPath)
+
+	if err != nil {
+		t.Fatalf("Got error %v reading the output file", err)
+	}
+
+	if string(got) != files[outputPath] {
+		t.Errorf("Got %s, wanted the output file to be left as it was", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		t.Fatalf("Got error %v reading the output directory", err)
+	}
+
+	if len(entries) != len(files) {
+		t.Errorf("Got %d files in the output directory, wanted the temporary file to be removed", len(entries))
+	}
+}
+
+func TestRunOutputDir(t *testing.T) {
+
+	configPath, inputPath := writeTestFiles(t)
+	secondInputPath := filepath.Join(filepath.Dir(inputPath), "second.xml")
+	outputDir := filepath.Join(t.TempDir(), "out")
+
+	err := os.WriteFile(secondInputPath, []byte(`<Patients><Patient ID="2"><DateOfBirth>2000-01-01</DateOfBirth></Patient></Patients>`), 0644)
+
+	if err != nil {
+		t.Fatalf("Got error %v writing the test files", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--quiet", "--format", "ndjson", "--as-of", "2025-02-01", "--config", configPath, "--output-dir", outputDir, inputPath, secondInputPath, "-"}, strings.NewReader(testInput), &stdout, &stderr)
+
+	if code != exitSuccess {
+		t.Fatalf("Got exit code %d, wanted %d, with stderr %s", code, exitSuccess, stderr.String())
+	}
+
+	wants := map[string]string{
+		"input.ndjson":  `{"id":1,"age":39}` + "\n",
+		"second.ndjson": `{"id":2,"age":25}` + "\n",
+		"stdin.ndjson":  `{"id":1,"age":39}` + "\n",
+	}
+
+	for name, want := range wants {
+		got, err := os.ReadFile(filepath.Join(outputDir, name))
+
+		if err != nil {
+			t.Fatalf("Got error %v reading the output file %s", err, name)
+		}
+
+		if string(got) != want {
+			t.Errorf("Got %s in %s, wanted %s", got, name, want)
+		}
+	}
+
+	// Inputs with the same name can't share an output directory
+	code = run([]string{"--quiet", "--config", configPath, "--output-dir", outputDir, inputPath, inputPath}, strings.NewReader(""), &stdout, &stderr)
+
+	if code != exitFailure {
+		t.Errorf("Got exit code %d, wanted %d for inputs with the same name", code, exitFailure)
+	}
+}
+
+func TestRunTransformError(t *testing.T) {
+
+	_, inputPath := writeTestFiles(t)
+	config := `{"Patients": [{"id": "<Patients.Patient.DateOfBirth type=int>"}]}`
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--quiet", "--config", "-", inputPath}, strings.NewReader(config), &stdout, &stderr)
+
+	if code != exitTransform {
+		t.Errorf("Got exit code %d, wanted %d, with stderr %s", code, exitTransform, stderr.String())
+	}
+}
+
+func TestRunMismatch(t *testing.T) {
+
+	_, inputPath := writeTestFiles(t)
+
+	var tests = []struct {
+		name   string
+		config string
+		flag   string
+	}{
+		{"missing value", `{"Patients": [{"id": "<Patients.Patient.ID>", "born": "<Patients.Patient.DateOfBirth>", "name": "<Patients.Patient.Name>"}]}`, "--strict"},
+		{"unmapped element", `{"Patients": [{"id": "<Patients.Patient.ID>"}]}`, "--strict"},
+		{"required value", `{"Patients": [{"name": "<Patients.Patient.Name required>"}]}`, "--lenient"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"--quiet", test.flag, "--config", "-", inputPath}, strings.NewReader(test.config), &stdout, &stderr)
+
+			if code != exitMismatch {
+				t.Errorf("Got exit code %d, wanted %d, with stderr %s", code, exitMismatch, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunValidateDiagnostics(t *testing.T) {
+
+	config := `{"Patients": [{"id": "<Patients.Patient.ID transform=shout>", "name": "<Staff.Doctor.Name>"}]}`
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"validate", "-"}, strings.NewReader(config), &stdout, &stderr)
+
+	if code != exitConfig {
+		t.Fatalf("Got exit code %d, wanted %d, with stderr %s", code, exitConfig, stderr.String())
+	}
+
+	for _, want := range []string{"-: $.Patients[0].id: unknown transformation 'shout'", "-: $.Patients[0].name: <Staff.Doctor.Name> isn't within the collection Patients"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Got %s, wanted it to contain %s", stderr.String(), want)
+		}
+	}
+}
+
+func TestRunLint(t *testing.T) {
+
+	configPath, inputPath := writeTestFiles(t)
+	config := `{"Patients": [{"id": "<Patients.Patient.ID>", "name": "<Patients.Patient.Name>"}]}`
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lint", "--config", "-", inputPath}, strings.NewReader(config), &stdout, &stderr)
+
+	if code != exitSuccess {
+		t.Fatalf("Got exit code %d, wanted %d, with stderr %s", code, exitSuccess, stderr.String())
+	}
+
+	want := `Collections and the number of objects in each:
+  Patients  1
+
+Matched symbols and the number of objects that each matched:
+  <Patients.Patient.ID>  1
+
+Symbols that never matched:
+  <Patients.Patient.Name>
+
+XML paths that the config file doesn't refer to and the number of times that each appeared:
+  Patients.Patient.DateOfBirth  1
+`
+
+	if stdout.String() != want {
+		t.Errorf("Got %s, wanted %s", stdout.String(), want)
+	}
+
+	code = run([]string{"lint", "--config", configPath, "-"}, strings.NewReader("<Patients>"), &stdout, &stderr)
+
+	if code != exitSyntax {
+		t.Errorf("Got exit code %d, wanted %d, with stderr %s", code, exitSyntax, stderr.String())
+	}
+}
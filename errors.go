@@ -0,0 +1,237 @@
+// -----------------------------------------------------------------------------
+// File     : errors.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains the errors that a Converter returns, one type for each
+// kind of problem, so that callers can tell a bad configuration file apart
+// from a bad XML input using errors.As.
+//
+// Example:
+// var syntaxError *gopherhole.SyntaxError
+// if errors.As(err, &syntaxError) { ... }
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"fmt"
+)
+
+// -----------------------------------------------------------------------------
+// Type     : ConfigError
+//
+// Abstract :
+// A ConfigError describes why a configuration file couldn't be used, either
+// because it couldn't be read or because its contents are invalid. Errors that
+// concern a single collection name the collection.
+// -----------------------------------------------------------------------------
+type ConfigError struct {
+	Collection string // The collection that the problem was found in, if any
+	Err        error  // The problem
+}
+
+// -----------------------------------------------------------------------------
+// Method       : ConfigError.Error()
+// Input        : none
+// Output       : A string describing the error
+// Side Effects : none
+//
+// Abstract :
+// This method implements the error interface for ConfigError.
+// -----------------------------------------------------------------------------
+func (e *ConfigError) Error() string {
+
+	if e.Collection != "" {
+		return fmt.Sprintf("invalid configuration for collection %s: %v", e.Collection, e.Err)
+	}
+
+	return e.Err.Error()
+}
+
+// -----------------------------------------------------------------------------
+// Method       : ConfigError.Unwrap()
+// Input        : none
+// Output       : The underlying error
+// Side Effects : none
+//
+// Abstract :
+// This method allows errors.Is and errors.As to inspect the underlying error.
+// -----------------------------------------------------------------------------
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// -----------------------------------------------------------------------------
+// Type     : InputError
+//
+// Abstract :
+// An InputError describes why XML input couldn't be read, e.g. because a file
+// couldn't be opened or a read failed partway through. It doesn't describe
+// problems with the XML itself, which are SyntaxErrors.
+// -----------------------------------------------------------------------------
+type InputError struct {
+	Err error // The problem
+}
+
+// -----------------------------------------------------------------------------
+// Method       : InputError.Error()
+// Input        : none
+// Output       : A string describing the error
+// Side Effects : none
+//
+// Abstract :
+// This method implements the error interface for InputError.
+// -----------------------------------------------------------------------------
+func (e *InputError) Error() string {
+	return fmt.Sprintf("error reading the input XML: %v", e.Err)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : InputError.Unwrap()
+// Input        : none
+// Output       : The underlying error
+// Side Effects : none
+//
+// Abstract :
+// This method allows errors.Is and errors.As to inspect the underlying error.
+// -----------------------------------------------------------------------------
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// -----------------------------------------------------------------------------
+// Type     : SyntaxError
+//
+// Abstract :
+// A SyntaxError describes malformed XML, along with the line and column at
+// which the decoder detected the problem, which may be just past the mistake
+// itself. Lines and columns are counted from 1.
+// -----------------------------------------------------------------------------
+type SyntaxError struct {
+	Line    int    // The line of the input at which the problem was found
+	Column  int    // The column of the input at which the problem was found
+	Message string // A description of the problem
+}
+
+// -----------------------------------------------------------------------------
+// Method       : SyntaxError.Error()
+// Input        : none
+// Output       : A string describing the error
+// Side Effects : none
+//
+// Abstract :
+// This method implements the error interface for SyntaxError.
+// -----------------------------------------------------------------------------
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("XML syntax error on line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// -----------------------------------------------------------------------------
+// Type     : TransformError
+//
+// Abstract :
+// A TransformError describes why a value found in the XML couldn't be
+// transformed or converted to the type that its symbol names. Transform holds
+// the name of the transformation that failed, or the type modifier if the
+// value couldn't be converted to its type.
+//
+// Example: For <Patients.Patient.DateOfBirth transform=yearsElapsed> and a
+// date of birth of 'unknown', Transform is 'yearsElapsed' and Value is
+// 'unknown'
+// -----------------------------------------------------------------------------
+type TransformError struct {
+	Symbol    string // The find and replace symbol being filled in
+	Transform string // The transformation or type modifier that failed
+	Value     string // The value that the transformation was given
+	Err       error  // The problem
+}
+
+// -----------------------------------------------------------------------------
+// Method       : TransformError.Error()
+// Input        : none
+// Output       : A string describing the error
+// Side Effects : none
+//
+// Abstract :
+// This method implements the error interface for TransformError.
+// -----------------------------------------------------------------------------
+func (e *TransformError) Error() string {
+	return fmt.Sprintf("failed to apply %s to '%s' in %s: %v", e.Transform, e.Value, e.Symbol, e.Err)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : TransformError.Unwrap()
+// Input        : none
+// Output       : The underlying error
+// Side Effects : none
+//
+// Abstract :
+// This method allows errors.Is and errors.As to inspect the underlying error.
+// -----------------------------------------------------------------------------
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+// -----------------------------------------------------------------------------
+// Type     : MissingValueError
+//
+// Abstract :
+// A MissingValueError describes a symbol that no value was found for, either
+// because the symbol has the required modifier or because the Converter is in
+// strict mode. In strict mode, a $repeat key that matched no elements is
+// reported as '$repeat' followed by its xmlKey. The line and column are those
+// at which the object that the symbol belongs to was closed. Lines and
+// columns are counted from 1.
+//
+// Example: For <Patients.Patient.MiddleName required> and a patient without a
+// middle name, Symbol is '<Patients.Patient.MiddleName required>' and
+// Collection is 'Patients'
+// -----------------------------------------------------------------------------
+type MissingValueError struct {
+	Symbol     string // The find and replace symbol that no value was found for
+	Collection string // The collection of the object that the symbol belongs to
+	Line       int    // The line of the input at which the object was closed
+	Column     int    // The column of the input at which the object was closed
+}
+
+// -----------------------------------------------------------------------------
+// Method       : MissingValueError.Error()
+// Input        : none
+// Output       : A string describing the error
+// Side Effects : none
+//
+// Abstract :
+// This method implements the error interface for MissingValueError.
+// -----------------------------------------------------------------------------
+func (e *MissingValueError) Error() string {
+	return fmt.Sprintf("no value found for %s in the %s object ending on line %d, column %d", e.Symbol, e.Collection, e.Line, e.Column)
+}
+
+// -----------------------------------------------------------------------------
+// Type     : UnmappedElementError
+//
+// Abstract :
+// An UnmappedElementError describes an XML element found in strict mode that
+// the configuration file doesn't refer to, along with the line and column just
+// past the element's start tag. Lines and columns are counted from 1.
+// -----------------------------------------------------------------------------
+type UnmappedElementError struct {
+	Path   string // The xmlKey of the element, e.g. Patients.Patient.Nickname
+	Line   int    // The line of the input at which the element was found
+	Column int    // The column of the input at which the element was found
+}
+
+// -----------------------------------------------------------------------------
+// Method       : UnmappedElementError.Error()
+// Input        : none
+// Output       : A string describing the error
+// Side Effects : none
+//
+// Abstract :
+// This method implements the error interface for UnmappedElementError.
+// -----------------------------------------------------------------------------
+func (e *UnmappedElementError) Error() string {
+	return fmt.Sprintf("element %s on line %d, column %d isn't referred to by the configuration", e.Path, e.Line, e.Column)
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,200 @@
+package gopherhole
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// failingReader supplies its contents and then fails
+type failingReader struct {
+	r io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+
+	n, err := f.r.Read(p)
+
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+
+	return n, err
+}
+
+func TestNewConverterConfigError(t *testing.T) {
+
+	var tests = []struct {
+		config         string
+		wantCollection string
+	}{
+		{`{`, ""},
+		{`{"Patients": {}}`, "Patients"},
+		{`{"Patients": ["<Patients.Patient.Name transform=>"]}`, "Patients"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.config, func(t *testing.T) {
+			_, err := NewConverter(strings.NewReader(test.config))
+
+			var configError *ConfigError
+
+			if !errors.As(err, &configError) {
+				t.Fatalf("Got error %v, wanted a *ConfigError", err)
+			}
+
+			if configError.Collection != test.wantCollection {
+				t.Errorf("Got collection %s, wanted %s", configError.Collection, test.wantCollection)
+			}
+		})
+	}
+}
+
+func TestConvertSyntaxError(t *testing.T) {
+
+	var tests = []struct {
+		name       string
+		input      string
+		wantLine   int
+		wantColumn int
+	}{
+		{"mismatched tag", "<Patients>\n  <Patient>\n    <Name>John</Nme>", 3, 21},
+		{"unclosed element", "<Patients>\n  <Patient>", 2, 12},
+		{"invalid character", "<Patients>\n  <Patient ID=1>", 2, 16},
+	}
+
+	converter, err := NewConverter(strings.NewReader(`{"Patients": [{"name": "<Patients.Patient.Name>"}]}`))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := converter.Convert(strings.NewReader(test.input), io.Discard)
+
+			var syntaxError *SyntaxError
+
+			if !errors.As(err, &syntaxError) {
+				t.Fatalf("Got error %v, wanted a *SyntaxError", err)
+			}
+
+			if syntaxError.Line != test.wantLine || syntaxError.Column != test.wantColumn {
+				t.Errorf("Got line %d, column %d, wanted line %d, column %d", syntaxError.Line, syntaxError.Column, test.wantLine, test.wantColumn)
+			}
+		})
+	}
+}
+
+func TestConvertInputError(t *testing.T) {
+
+	converter, err := NewConverter(strings.NewReader(`{"Patients": [{"name": "<Patients.Patient.Name>"}]}`))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	input := &failingReader{r: strings.NewReader(`<Patients><Patient><Name>John</Name></Patient>`)}
+	err = converter.Convert(input, io.Discard)
+
+	var inputError *InputError
+
+	if !errors.As(err, &inputError) {
+		t.Fatalf("Got error %v, wanted an *InputError", err)
+	}
+}
+
+func TestConvertMissingValueError(t *testing.T) {
+
+	var tests = []struct {
+		name       string
+		config     string
+		mode       Mode
+		wantSymbol string
+		wantLine   int
+	}{
+		{"required", `{"Patients": [{"id": "<Patients.Patient.ID required>"}]}`, ModeDefault, "<Patients.Patient.ID required>", 2},
+		{"required lenient", `{"Patients": [{"id": "<Patients.Patient.ID required>"}]}`, ModeLenient, "<Patients.Patient.ID required>", 2},
+		{"strict", `{"Patients": [{"name": "<Patients.Patient.Name>", "id": "Patient <Patients.Patient.ID>"}]}`, ModeStrict, "<Patients.Patient.ID>", 3},
+		{"required repeat", `{"Patients": [{"n": "<Patients.Patient.Phone repeat required>"}]}`, ModeDefault, "<Patients.Patient.Phone repeat required>", 2},
+		{"strict repeat", `{"Patients": [{"name": "<Patients.Patient.Name>", "id": "<Patients.Patient.ID>", "n": "<Patients.Patient.Phone repeat>"}]}`, ModeStrict, "<Patients.Patient.Phone repeat>", 2},
+		{"strict $repeat", `{"Patients": [{"name": "<Patients.Patient.Name>", "id": "<Patients.Patient.ID>", "n": [{"$repeat": "Patients.Patient.Phone", "number": "<Patients.Patient.Phone>"}]}]}`, ModeStrict, "$repeat Patients.Patient.Phone", 2},
+	}
+
+	input := "<Patients>\n  <Patient><Name>John</Name><ID></ID></Patient>\n  <Patient><Name>Jane</Name></Patient>\n</Patients>"
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converter, err := NewConverter(strings.NewReader(test.config))
+
+			if err != nil {
+				t.Fatalf("Got error %v creating the converter", err)
+			}
+
+			converter.Mode = test.mode
+			err = converter.Convert(strings.NewReader(input), io.Discard)
+
+			var missingValueError *MissingValueError
+
+			if !errors.As(err, &missingValueError) {
+				t.Fatalf("Got error %v, wanted a *MissingValueError", err)
+			}
+
+			if missingValueError.Symbol != test.wantSymbol || missingValueError.Collection != "Patients" {
+				t.Errorf("Got symbol %s in %s, wanted %s in Patients", missingValueError.Symbol, missingValueError.Collection, test.wantSymbol)
+			}
+
+			if missingValueError.Line != test.wantLine {
+				t.Errorf("Got line %d, wanted line %d", missingValueError.Line, test.wantLine)
+			}
+		})
+	}
+}
+
+func TestConvertUnmappedElementError(t *testing.T) {
+
+	var tests = []struct {
+		name     string
+		input    string
+		wantPath string
+		wantLine int
+	}{
+		{"in an object", "<Hospital><Patients>\n  <Patient><Name>John</Name><Nickname>JJ</Nickname></Patient>\n</Patients></Hospital>", "Hospital.Patients.Patient.Nickname", 2},
+		{"outside a collection", "<Hospital>\n  <Patients></Patients>\n  <Staff></Staff>\n</Hospital>", "Hospital.Staff", 3},
+		{"mapped", "<Hospital>\n  <Patients>\n    <Patient><Name>John</Name><Phone>1</Phone></Patient>\n  </Patients>\n</Hospital>", "", 0},
+	}
+
+	config := `{"Hospital.Patients": [{"name": "<Hospital.Patients.Patient.Name>", "phones": [{"$repeat": "Hospital.Patients.Patient.Phone"}]}]}`
+	converter, err := NewConverter(strings.NewReader(config))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	converter.Mode = ModeStrict
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := converter.Convert(strings.NewReader(test.input), io.Discard)
+
+			var unmappedElementError *UnmappedElementError
+
+			if test.wantPath == "" {
+				if err != nil {
+					t.Fatalf("Got error %v, wanted no error", err)
+				}
+
+				return
+			}
+
+			if !errors.As(err, &unmappedElementError) {
+				t.Fatalf("Got error %v, wanted an *UnmappedElementError", err)
+			}
+
+			if unmappedElementError.Path != test.wantPath || unmappedElementError.Line != test.wantLine {
+				t.Errorf("Got %s on line %d, wanted %s on line %d", unmappedElementError.Path, unmappedElementError.Line, test.wantPath, test.wantLine)
+			}
+		})
+	}
+}
@@ -1,142 +1,297 @@
 // -----------------------------------------------------------------------------
-// Application : gopherhole
-// Engineer    : Christian Westbrook
-// Abstract    :
-// This application converts an input XML file into JSON data based
-// on the requirements specified in an accompanying configuration file.
+// Package  : gopherhole
+// Engineer : Christian Westbrook
+// Abstract :
+// This package converts XML data into JSON data based on the requirements
+// specified in an accompanying configuration file. The conversion engine is
+// exposed through the Converter type so that it can be embedded in other Go
+// programs, and the gopherhole command-line application is a thin wrapper
+// around it.
 //
 // Example Usage:
-// gopherhole             <- defaults to converting input.xml using config.json
-// gopherhole myxmlfile.xml                    <- defaults to using config.json
-// gopherhole myxmlfile.xml myconfigfile.json
-//
-// In any case, JSON data is generated from the input XML file in a format
-// specified the input configuration file and is printed to the console.
+// converter, err := gopherhole.NewConverter(configReader)
+// err = converter.Convert(xmlReader, os.Stdout)
 // -----------------------------------------------------------------------------
 
-package main
+package gopherhole
 
 import (
-	"bytes"
-	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"os"
-	"regexp"
-	"strconv"
+	"io"
 	"strings"
 	"time"
 	"unicode"
 )
 
-// ROADMAP
-// - Handle the same find and replace key occuring multiple times in the config file
-// - Handle parent key alias' e.g. Patients -> patients
-// - Convert numbers to numbers (rather than to strings)
-// - Take in the config file as a flag option
-// - Take in a flag option that specifies the output file name
-
-// Package level constants
-const FindAndReplaceExpression = "<[a-zA-Z.=\\s]+>" // Regex for use in replacing the config file's find and replace symbols
+// -----------------------------------------------------------------------------
+// CONVERTER
+// -----------------------------------------------------------------------------
 
 // -----------------------------------------------------------------------------
-// Function : main()
-// Input    : none
+// Type     : Converter
+//
+// Abstract :
+// A Converter holds a parsed configuration file along with the parsed find
+// and replace symbols that its object definitions contain. A single
+// Converter can be used to convert any number of XML inputs.
+//
+// Setting AsOf fixes the time that transformations such as yearsElapsed
+// measure from, so that the same input always produces the same output.
+// Setting Stream writes each object as soon as it has been converted rather
+// than holding the whole output in memory, see streamWriter. Setting Format to
+// FormatNDJSON writes each object on its own line, optionally tagged with its
+// collection's name in the field named by CollectionKey, and always streams.
+// Setting Compact writes JSON output without line breaks or indentation.
+// Setting Mode decides what happens to symbols that no value was found for,
+// see Mode. Setting Whitespace decides how the whitespace in text found in
+// the input XML is handled, unless a symbol's whitespace modifier says
+// otherwise, see Whitespace.
 //
-// Command-line Arguments :
-// 1 - An XML file to be configurably converted into JSON (defaults to input.xml)
-// 2 - config.json - A configuration file that uses replacement symbols to specify
-// an output JSON file format (defaults to config.json)
+// XML namespaces are ignored, and elements are matched by their local names
+// alone, unless the configuration file declares namespace prefixes under the
+// $namespaces key. Once it does, a prefixed segment of an xmlKey such as
+// p:Patient only matches a Patient element in the namespace that p stands for,
+// and an unprefixed segment only matches an element in no namespace.
 //
-// Output       : none
-// Side Effects : Converted JSON is printed to the console
+// Example: {"$namespaces": {"p": "urn:example:patients"}, "p:Patients": [...]}
+// -----------------------------------------------------------------------------
+type Converter struct {
+	AsOf          time.Time  // The time that relative date transformations measure from, or the current time if zero
+	Stream        bool       // Whether to write each object as soon as it has been converted
+	Format        Format     // The layout of the output
+	Compact       bool       // Whether to leave line breaks and indentation out of JSON output
+	CollectionKey string     // The field that names each object's collection in NDJSON output, if any
+	Mode          Mode       // How symbols and elements that the configuration doesn't match are handled
+	Whitespace    Whitespace // How whitespace in the text of the input XML is handled
+
+	configMap   map[string]interface{} // The parsed configuration file
+	collections []string               // The collection keys in the order they were written
+	symbols     map[string]Symbol      // Find and replace symbols by their text
+	references  map[string]bool        // Every xmlKey that the configuration refers to, including their ancestors
+	prefixes    map[string]string      // Namespace URIs by the prefix declared for them, or nil if namespaces are ignored
+	namespaces  map[string]string      // Namespace prefixes by the URI that they stand for
+}
+
+// -----------------------------------------------------------------------------
+// Type     : Mode
+//
+// Abstract :
+// A Mode decides how a Converter handles data that its configuration file
+// doesn't match. Regardless of the Mode, a symbol with the required modifier
+// must be given a value, either from the XML or from its default modifier.
+// -----------------------------------------------------------------------------
+type Mode int
+
+const (
+	// ModeDefault leaves a symbol that no value was found for in place, and
+	// ignores XML elements that the configuration doesn't refer to
+	ModeDefault Mode = iota
+
+	// ModeLenient replaces a symbol that no value was found for with its
+	// default modifier if it has one, or otherwise with null when the symbol
+	// is the whole value and with nothing when it's part of a longer string
+	ModeLenient
+
+	// ModeStrict returns a *MissingValueError for a symbol that no value was
+	// found for and that has no default modifier, including a repeated
+	// symbol or $repeat key that matched nothing, and an
+	// *UnmappedElementError for an XML element that the configuration doesn't
+	// refer to
+	ModeStrict
+)
+
+// -----------------------------------------------------------------------------
+// Type     : Whitespace
 //
 // Abstract :
-// This function serves as the entry point to gopherhole. It takes in an XML
-// file to be converted and a configuration file that specifies the output and
-// generates JSON data from the given XML based on the specification.
+// A Whitespace policy decides how the whitespace in an element's text or an
+// attribute's value is handled before the value replaces a symbol. An
+// element's text is gathered in full before the policy is applied, including
+// text within CDATA sections and on either side of comments.
 //
-// Example Usage:
-// gopherhole             <- defaults to converting input.xml using config.json
-// gopherhole myxmlfile.xml                    <- defaults to using config.json
-// gopherhole myxmlfile.xml myconfigfile.json
+// Example: For <Name> John  Doe </Name>, WhitespaceTrim gives 'John  Doe' and
+// WhitespaceCollapse gives 'John Doe'
 // -----------------------------------------------------------------------------
-func main() {
+type Whitespace int
 
-	// Introduce the application
-	intro()
+const (
+	// WhitespaceDefault keeps text as it was written, except that text made
+	// up only of whitespace is treated as empty
+	WhitespaceDefault Whitespace = iota
 
-	// Get the input files
-	inputXMLPath := "input.xml"     // Default input XML file name
-	configFilePath := "config.json" // Default configuration file name
+	// WhitespacePreserve keeps text exactly as it was written
+	WhitespacePreserve
 
-	// Get command-line arguments
-	if len(os.Args) > 1 {
-		inputXMLPath = os.Args[1]
-	}
-	if len(os.Args) > 2 {
-		configFilePath = os.Args[2]
-	}
+	// WhitespaceTrim removes leading and trailing whitespace
+	WhitespaceTrim
 
-	fmt.Println("Processing", inputXMLPath, "using", configFilePath)
-	fmt.Println()
+	// WhitespaceCollapse removes leading and trailing whitespace and
+	// replaces each run of whitespace within the text with a single space
+	WhitespaceCollapse
+)
 
-	// -------------------------------------------------------------------------
-	// OPEN INPUT FILES
-	// -------------------------------------------------------------------------
-	rawXMLInput, err := os.ReadFile(inputXMLPath)
+// -----------------------------------------------------------------------------
+// Function     : NewConverter()
+// Input        :
+// config - A reader supplying a configuration file that uses replacement
+// symbols to specify an output JSON format
+//
+// Output       :
+// converter - A Converter that is ready to convert XML using the given
+// configuration
+// err - A *ConfigError describing why the configuration could not be used
+//
+// Side Effects : The input reader is read to completion
+//
+// Abstract :
+// This function reads a configuration file, checks that each collection
+// within it contains an object definition, and parses the find and replace
+// symbols within those definitions for use in later conversions. The order in
+// which collections and fields are written is kept for the output. Any
+// namespace prefixes declared under the $namespaces key are recorded, and
+// every prefix used by the configuration must be declared. A configuration
+// file that ValidateConfig() would report a problem in isn't accepted.
+// -----------------------------------------------------------------------------
+func NewConverter(config io.Reader) (*Converter, error) {
+
+	rawConfigInput, err := io.ReadAll(config)
 
 	if err != nil {
-		fmt.Println("Error opening the input XML file:", err)
+		return nil, &ConfigError{Err: fmt.Errorf("error reading the config file: %w", err)}
 	}
 
-	// Example of a config file
-	rawConfigInput, err := os.ReadFile(configFilePath)
+	// Unmarshal configuration data, keeping the order of its keys
+	configValue, err := unmarshalOrdered(rawConfigInput)
 
 	if err != nil {
-		fmt.Println("Error opening the config file:", err)
+		return nil, &ConfigError{Err: fmt.Errorf("invalid configuration JSON: %w", err)}
 	}
-	// -------------------------------------------------------------------------
 
-	// -------------------------------------------------------------------------
-	// READ CONFIG FILE
-	// -------------------------------------------------------------------------
-	// Create a map to store the input JSON configuration
-	configMap := make(map[string]interface{})
+	configObject, ok := configValue.(*orderedMap)
 
-	// Unmarshal configuration data into the map
-	err = json.Unmarshal(rawConfigInput, &configMap)
+	if !ok {
+		return nil, &ConfigError{Err: errors.New("invalid configuration JSON: expected an object mapping collection keys to object definitions")}
+	}
 
-	if err != nil {
-		fmt.Println("Invalid configuration JSON:", err)
+	converter := &Converter{
+		configMap:  make(map[string]interface{}, len(configObject.keys)),
+		symbols:    make(map[string]Symbol),
+		references: make(map[string]bool),
 	}
 
-	// The given configuration file will include find and replace symbols
-	// for each kind of object that we intend to convert from XML to JSON,
-	// and so we need to store them to use later when we're iterating over
-	// the XML and converting it to the specified output
-	//
-	// Example: <Patients.Patient.DateOfBirth transform=yearsElapsed>
-	//               ^ name                     ^ modifier
+	// Every other key names a collection
+	for _, k := range configObject.keys {
+		if k == "$namespaces" {
+			err = converter.parseNamespaces(configObject.values[k])
 
-	// Build a record of find and replace symbols
-	findAndReplaceMaps := make(map[string]map[string]string)
+			if err != nil {
+				return nil, &ConfigError{Err: err}
+			}
 
-	// Build a record of modifiers
-	modifierMaps := make(map[string]map[string]map[string]string)
+			continue
+		}
 
-	// Build the find and replace maps and the modifier maps
-	for k, v := range configMap {
-		innerMap := v.([]interface{})[0].(map[string]interface{})
-		findAndReplaceMap, modifierMap := getReplacementMapAndModifiers(innerMap)
+		converter.configMap[k] = configObject.values[k]
+		converter.collections = append(converter.collections, k)
+	}
+
+	// Each collection in the configuration file must be a list containing the
+	// definition of the objects in that collection
+	for _, k := range converter.collections {
+		collection, ok := converter.configMap[k].([]interface{})
+
+		if !ok || len(collection) == 0 {
+			return nil, &ConfigError{Collection: k, Err: errors.New("expected a list containing an object definition")}
+		}
 
-		findAndReplaceMaps[k] = findAndReplaceMap
-		modifierMaps[k] = modifierMap
+		// Parse each of the definition's find and replace symbols
+		err = converter.parseSymbols(collection[0])
+
+		if err == nil {
+			err = converter.addReference(k)
+		}
+
+		if err != nil {
+			return nil, &ConfigError{Collection: k, Err: err}
+		}
 	}
 
-	// Example: Patient -> List of maps
-	parentKeyMap := make(map[string][]map[string]interface{})
-	// -------------------------------------------------------------------------
+	// Anything else that validation would report, such as a definition that
+	// isn't an object or a symbol outside of its collection, is an error too
+	diagnostics := validateConfigObject(configObject)
+
+	if len(diagnostics) > 0 {
+		return nil, &ConfigError{Err: errors.New(diagnostics[0].String())}
+	}
+
+	return converter, nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.Convert()
+// Input        :
+// r - A reader supplying the XML data to be converted
+// w - A writer that will receive the converted JSON data
+//
+// Output       :
+// err - An error describing why the conversion could not be completed
+//
+// Side Effects : Converted JSON is written to w
+//
+// Abstract :
+// This method converts a single XML input and writes the resulting
+// collections to the output writer as indented JSON, or as one line of JSON
+// per object. Use NewOutput to combine several XML inputs into one output.
+// -----------------------------------------------------------------------------
+func (c *Converter) Convert(r io.Reader, w io.Writer) error {
+
+	output, err := c.NewOutput(w)
+
+	if err != nil {
+		return err
+	}
+
+	err = output.Convert(r)
+
+	if err != nil {
+		return err
+	}
+
+	return output.Close()
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.convert()
+// Input        :
+// r - A reader supplying the XML data to be converted
+// out - The writer that each output object is handed to
+// report - A coverage report to record the input in instead of generating
+// output objects, or nil to convert the input
+//
+// Output       :
+// err - An error describing why the conversion could not be completed, which
+// is a *SyntaxError for malformed XML, an *InputError if the XML couldn't be
+// read, a *TransformError if a value couldn't be transformed, or a
+// *MissingValueError or *UnmappedElementError if the XML doesn't match the
+// configuration
+//
+// Side Effects : Output objects are handed to out, or the input is recorded
+// in the report
+//
+// Abstract :
+// This method iterates over the tokens of the input XML and builds output
+// objects based on the configuration that the Converter was created with.
+// When given a report, no output objects are built. Instead, the report
+// records each object, the symbols that it has values for, and the elements
+// and attributes that the configuration doesn't refer to.
+//
+// Each object is generated as soon as its element is closed, and only the
+// elements of the object currently being read are held in memory. When the
+// Converter streams, objects are written out as soon as they're generated.
+// -----------------------------------------------------------------------------
+func (c *Converter) convert(r io.Reader, out objectWriter, report *Report) error {
 
 	// -------------------------------------------------------------------------
 	// READ XML
@@ -148,302 +303,1030 @@ func main() {
 	// Representation : ["Patients", "Patient", "FirstName"]
 	xmlKeySlice := []string{}
 
-	// Create an XML decoder
-	xmlReader := bytes.NewReader(rawXMLInput)
-	decoder := xml.NewDecoder(xmlReader)
+	// Keep track of the collection that we're currently inside of, if any, and
+	// of how deep into the hierarchy that collection's element was found. The
+	// children of a collection's element are the objects of that collection.
+	//
+	// Example: For the collection Hospital.Patients, parentKey is
+	// 'Hospital.Patients' and collectionDepth is 2
+	parentKey := ""
+	collectionDepth := 0
+
+	// Keep the collection's element so that its attributes can be given to
+	// each of the collection's objects
+	//
+	// Example: <Patients Facility="North"> for the symbol <Patients.@Facility>
+	var collectionScope *scope
+
+	// While we're inside of an object, record the elements that it contains.
+	// Once the object's element is closed, these elements are used to fill in
+	// the object's definition.
+	//
+	// Example: Patient -> [FirstName, LastName, Address -> [City]]
+	var objectElement *element
+	elementStack := []*element{}
+
+	// Create an XML decoder that can read UTF-16 and the encodings that an
+	// XML declaration may name
+	decoder := xml.NewDecoder(newInputReader(r))
+
+	// Keep the reason that an encoding named by the XML declaration can't be
+	// read, which the decoder would otherwise only give as a message
+	var charsetErr error
+
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		reader, err := charsetReader(charset, input)
+		charsetErr = err
+		return reader, err
+	}
 
 	// Iterate over tokens in the XML decoder
 	for {
 
-		// Unpack the next token
-		token, error := decoder.Token()
+		// Unpack the next token, noting where it begins
+		line, column := decoder.InputPos()
+		token, err := decoder.Token()
 
-		if error != nil {
+		if err == io.EOF {
 			break
 		}
 
+		// An unsupported encoding is reported at the XML declaration that
+		// names it
+		if charsetErr != nil {
+			return &SyntaxError{Line: line, Column: column, Message: charsetErr.Error()}
+		}
+
+		if err != nil {
+			return decodeError(decoder, err)
+		}
+
 		// Switch on the token's asserted type
 		switch t := token.(type) {
 		case xml.ProcInst:
 		case xml.StartElement:
 
 			// Push the new element name to the key slice
-			xmlKeySlice = append(xmlKeySlice, t.Name.Local)
+			xmlKeySlice = append(xmlKeySlice, c.elementName(t.Name))
+			xmlKey := strings.Join(xmlKeySlice, ".")
 
-			// We need to track how deep into the hierarchy we are at this point
-			switch len(xmlKeySlice) {
-			case 1: // If we encounter a new parent key, initialize it with an empty map
-				parentKeyMap[xmlKeySlice[0]] = []map[string]interface{}{}
-			case 2: // If we encounter a new object within a parent, add an empty object to the parent list
+			// In strict mode, every element must be accounted for by the
+			// configuration. The elements directly within a collection are
+			// its objects, which are accounted for by the collection itself.
+			referenced := c.references[xmlKey] || (parentKey != "" && len(xmlKeySlice) == collectionDepth+1)
+
+			switch {
+			case report != nil:
+				report.addElement(xmlKey, referenced, c.attributeNames(t), c.references)
+			case c.Mode == ModeStrict && !referenced:
+				line, column := decoder.InputPos()
+				return &UnmappedElementError{Path: xmlKey, Line: line, Column: column}
+			}
 
-				// Confirm that the parent exists
-				_, ok := parentKeyMap[xmlKeySlice[0]]
+			// If we aren't inside of a collection, check whether this element
+			// is one of the collections named in the configuration file
+			if parentKey == "" {
+				_, ok := c.configMap[xmlKey]
 
-				if !ok {
-					fmt.Println("Came across an object for which there was no parent key")
-					continue
-				}
+				if ok {
+					parentKey = xmlKey
+					collectionDepth = len(xmlKeySlice)
+					collectionScope = &scope{path: xmlKey, element: c.newElement(t)}
 
-				// Generate a map to contain the new object
-				xmlKey := strings.Join(xmlKeySlice, ".")
-				outputObjectMap := generateOutputObjectMap(configMap, xmlKey)
-
-				// Add the new map to the list of maps
-				outputObjects := parentKeyMap[xmlKeySlice[0]]
-				outputObjects = append(outputObjects, outputObjectMap)
-				parentKeyMap[xmlKeySlice[0]] = outputObjects
-
-				// If we come across a tracked attribute
-				for _, a := range t.Attr {
-					parentKey := xmlKeySlice[0]
-					xmlKey := strings.Join(xmlKeySlice, ".") + "." + a.Name.Local
-
-					// If the given attribute is related to a find and replace symbol
-					outputFieldKey, ok := findAndReplaceMaps[parentKey][xmlKey]
-
-					if ok {
-						// We need to find and replace the patient key with
-						// this XML token's value in the given output patient field
-						outputField := outputObjects[len(outputObjects)-1][outputFieldKey].(string)
-						outputField = strings.Replace(outputField, "<"+xmlKey+">", a.Value, 1)
-						outputObjects[len(outputObjects)-1][outputFieldKey] = outputField
+					// Record the collection even if it turns out to be empty
+					err := out.writeObject(parentKey, nil)
+
+					if err != nil {
+						return err
 					}
 				}
 
-			case 3:
-			default:
-				// TODO: You could just look at the last three tiers
-				// and replicate the upper tiers
-				fmt.Println("Unhandled XML hierarchy")
+				continue
 			}
 
+			// The collection's element itself isn't part of any object
+			if len(xmlKeySlice) <= collectionDepth {
+				continue
+			}
+
+			// Record the new element, beginning a new object if the element
+			// is a direct child of the collection's element
+			e := c.newElement(t)
+
+			if len(xmlKeySlice) == collectionDepth+1 {
+				objectElement = e
+			} else {
+				parent := elementStack[len(elementStack)-1]
+				parent.children = append(parent.children, e)
+			}
+
+			elementStack = append(elementStack, e)
+
 		case xml.CharData:
 
-			// If we encounter whitespace, ignore it
-			if IsWhitespace(string(t)) {
+			// Only text that belongs to an object can be used to fill symbols
+			if len(elementStack) == 0 {
 				break
 			}
 
-			// If we come across one of the configured patient keys
-			parentKey := xmlKeySlice[0]
-			xmlKey := strings.Join(xmlKeySlice, ".")
+			// An element's text may arrive in several pieces, split by CDATA
+			// sections, comments or processing instructions, so gather every
+			// piece until the element is closed. Whitespace is handled once
+			// the text is complete.
+			e := elementStack[len(elementStack)-1]
+			e.text += string(t)
 
-			outputJSONKey, ok := findAndReplaceMaps[parentKey][xmlKey]
+		case xml.EndElement:
 
-			outputObjects := parentKeyMap[xmlKeySlice[0]]
+			if len(elementStack) > 0 {
+				elementStack = elementStack[:len(elementStack)-1]
+			}
 
-			if ok {
-				// We need to find and replace the patient key with
-				// this XML token's value in the given output patient field
-				fieldValue := string(t)
+			// If we're closing an object's element, fill in the object's
+			// definition and add it to its collection
+			if parentKey != "" && len(xmlKeySlice) == collectionDepth+1 {
+				definition := c.configMap[parentKey].([]interface{})[0]
+				objectScope := &scope{path: strings.Join(xmlKeySlice, "."), element: objectElement, parent: collectionScope}
+				objectElement = nil
+
+				// When reporting on coverage, record the object instead
+				if report != nil {
+					report.addObject(parentKey, objectScope)
+				} else {
+					outputObject, err := c.generateOutputObject(definition, objectScope)
+
+					// Missing values are reported at the end of their object
+					var missingValueError *MissingValueError
+
+					if errors.As(err, &missingValueError) {
+						missingValueError.Collection = parentKey
+						missingValueError.Line, missingValueError.Column = decoder.InputPos()
+					}
 
-				// First, check if there are any transformations necessary
-				transformation, ok := modifierMaps[parentKey][xmlKey]["transform"]
+					if err != nil {
+						return err
+					}
 
-				if ok {
-					switch transformation {
-					case "yearsElapsed":
-						fvPtr := &fieldValue // Get a pointer to fieldValue
-						*fvPtr = strconv.Itoa(YearsElapsed(string(t)))
-						xkPtr := &xmlKey // Get a pointer to xmlKey
-						*xkPtr = xmlKey + " transform=" + transformation
-					default:
-						fmt.Println("Unhandled transformation: ", transformation)
+					err = out.writeObject(parentKey, outputObject)
+
+					if err != nil {
+						return err
 					}
 				}
+			}
 
-				// Assign the token's value to the output field
-				outputField := outputObjects[len(outputObjects)-1][outputJSONKey].(string)
-				outputField = strings.Replace(outputField, "<"+xmlKey+">", fieldValue, 1)
-				outputObjects[len(outputObjects)-1][outputJSONKey] = outputField
+			// If we're closing a collection's element, we've left the collection
+			if len(xmlKeySlice) == collectionDepth {
+				parentKey = ""
+				collectionDepth = 0
+				collectionScope = nil
 			}
 
-		case xml.EndElement:
 			xmlKeySlice = xmlKeySlice[:len(xmlKeySlice)-1] // Pop the closed element
 		case xml.Comment:
 		case xml.Directive:
-		default:
-			fmt.Println("Unhandled token encountered")
 		}
 	}
 	// -------------------------------------------------------------------------
 
-	// -------------------------------------------------------------------------
-	// CONVERSION TO JSON
-	// -------------------------------------------------------------------------
-	// For each list of objects
-	// parentKeyMap := make(map[string][]map[string]interface{})
-	outputJSON := make(map[string][]map[string]interface{})
-	for k, v := range parentKeyMap {
-		outputJSON[k] = v
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : decodeError()
+// Input        :
+// decoder - The XML decoder that returned an error
+// err - The error that the decoder returned
+//
+// Output       :
+// A *SyntaxError if the XML is malformed, or an *InputError if it couldn't be
+// read
+//
+// Side Effects : none
+//
+// Abstract :
+// This function describes an error returned while reading XML tokens. Syntax
+// errors are given the line and column that the decoder had reached.
+// -----------------------------------------------------------------------------
+func decodeError(decoder *xml.Decoder, err error) error {
+
+	var syntaxError *xml.SyntaxError
+
+	if errors.As(err, &syntaxError) {
+		line, column := decoder.InputPos()
+		return &SyntaxError{Line: line, Column: column, Message: syntaxError.Msg}
 	}
 
-	// Marshal the output to JSON
-	jsonData, err := json.MarshalIndent(outputJSON, "", "  ")
+	return &InputError{Err: err}
+}
 
-	if err != nil {
-		fmt.Println("Error marshaling the output JSON:", err)
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// DATA STRUCTURES
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : element
+//
+// Abstract :
+// An element is a record of an XML element found within an object, along with
+// its attributes, its text and the elements nested within it. The elements of
+// an object are kept until the object's element is closed so that repeated
+// children can be gathered together when the object's definition is filled in.
+// -----------------------------------------------------------------------------
+type element struct {
+	name     string            // The element's local name, e.g. Patient
+	attrs    map[string]string // The element's attributes by name, e.g. ID
+	text     string            // The text directly within the element, as it was written
+	children []*element        // The elements nested within this element
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.newElement()
+// Input        : t - An XML start element token
+// Output       : A pointer to a new element record for the given token
+// Side Effects : none
+//
+// Abstract :
+// This method creates a record of an XML element from its start token. The
+// element and its attributes are named as described by elementName().
+// Namespace declarations aren't recorded as attributes.
+// -----------------------------------------------------------------------------
+func (c *Converter) newElement(t xml.StartElement) *element {
+
+	e := &element{name: c.elementName(t.Name), attrs: make(map[string]string, len(t.Attr))}
+
+	for _, a := range t.Attr {
+		if !isNamespaceDeclaration(a) {
+			e.attrs[c.elementName(a.Name)] = a.Value
+		}
 	}
 
-	fmt.Println("Output JSON")
-	fmt.Println()
-	fmt.Println(string(jsonData))
-	// -------------------------------------------------------------------------
+	return e
 }
 
 // -----------------------------------------------------------------------------
-// TRANSFORMATIONS
+// Type     : scope
+//
+// Abstract :
+// A scope pairs an element with the xmlKey at which it was found. Symbols are
+// resolved against the innermost scope whose xmlKey they begin with. The
+// collection's element forms the outermost scope, holding only its
+// attributes. An object's element forms a scope nested within it, and each
+// repeated element that a definition iterates over forms a scope nested
+// within the object's.
+//
+// Example: Within the scope Patients.Patient.Allergy, the symbol
+// <Patients.Patient.Allergy.Name> refers to the Name of that one allergy,
+// while <Patients.Patient.ID> is still resolved against the patient.
 // -----------------------------------------------------------------------------
+type scope struct {
+	path    string   // The xmlKey of the scope's element, e.g. Patients.Patient
+	element *element // The scope's element
+	parent  *scope   // The enclosing scope, or nil for a collection's scope
+}
 
 // -----------------------------------------------------------------------------
-// Function     : yearsElapsed()
-// Input        :
-// date - A string representing a date to be converted into the number of
-// years that have elapsed since the input date
+// Method       : scope.elements()
+// Input        : name - A string representing an xmlKey
+// Output       :
+// A list of every element found at the given xmlKey, in document order, and
+// the scope that the xmlKey was resolved against
 //
+// Side Effects : none
+//
+// Abstract :
+// This method finds every element located at an xmlKey, descending from the
+// innermost scope whose xmlKey the given xmlKey begins with.
+// -----------------------------------------------------------------------------
+func (s *scope) elements(name string) ([]*element, *scope) {
+
+	for current := s; current != nil; current = current.parent {
+
+		if name == current.path {
+			return []*element{current.element}, current
+		}
+
+		if !strings.HasPrefix(name, current.path+".") {
+			continue
+		}
+
+		found := []*element{current.element}
+
+		for _, token := range strings.Split(name[len(current.path)+1:], ".") {
+			children := []*element{}
+
+			for _, e := range found {
+				for _, child := range e.children {
+					if child.name == token {
+						children = append(children, child)
+					}
+				}
+			}
+
+			found = children
+		}
+
+		return found, current
+	}
+
+	return nil, nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : scope.values()
+// Input        : name - A string representing an xmlKey
 // Output       :
-// age - An integer representing the number of years that have elapsed since the
-// input date
+// A list of strings representing every value found at the given xmlKey, in
+// document order
 //
 // Side Effects : none
 //
 // Abstract :
-// This function takes in a date string and calculates the number of years that
-// have elapsed since that date.
+// This method finds the text of every element located at an xmlKey. An
+// xmlKey ending in an attribute, written with an '@', gives the value of that
+// attribute on every element located at the rest of the xmlKey that has it.
+//
+// The attributes of an object's element can also be named without an '@'.
+// These are only used when there's no element at the xmlKey.
+//
+// Example: For <Patient ID="12345"><Phone Type="home">555</Phone></Patient>,
+// <Patients.Patient.@ID>, <Patients.Patient.ID>, <Patients.Patient.Phone> and
+// <Patients.Patient.Phone.@Type> can all be resolved
 // -----------------------------------------------------------------------------
-func YearsElapsed(date string) int {
+func (s *scope) values(name string) []string {
 
-	d, err := time.Parse("2006-01-02", date)
+	values := []string{}
 
-	if err != nil {
-		fmt.Println("Failed to parse input date: ", err)
+	// Attributes are read from the elements that hold them
+	path, attribute, ok := strings.Cut(name, ".@")
+
+	if ok {
+		found, _ := s.elements(path)
+
+		for _, e := range found {
+			value, ok := e.attrs[attribute]
+
+			if ok {
+				values = append(values, value)
+			}
+		}
+
+		return values
+	}
+
+	found, resolved := s.elements(name)
+
+	for _, e := range found {
+		values = append(values, e.text)
+	}
+
+	if len(values) > 0 || resolved == nil || resolved.parent == nil {
+		return values
+	}
+
+	// Attributes named without an '@' are read from the object's element,
+	// which is the scope nested directly within the collection's
+	objectScope := resolved
+
+	for objectScope.parent.parent != nil {
+		objectScope = objectScope.parent
 	}
 
-	now := time.Now()
-	age := now.Year() - d.Year()
+	attribute, ok = strings.CutPrefix(name, objectScope.path+".")
 
-	// Has the given day of the year happened yet this year?
-	if now.YearDay() < d.YearDay() {
-		age--
+	if ok {
+		value, ok := objectScope.element.attrs[attribute]
+
+		if ok {
+			values = append(values, value)
+		}
 	}
 
-	return age
+	return values
 }
 
 // -----------------------------------------------------------------------------
-// DATA STRUCTURES
+// Method       : Converter.parseSymbols()
+// Input        :
+// definition - A typeless value taken from the configuration file that
+// represents the definition of an object to be added to a collection
+//
+// Output       :
+// err - A *SymbolError describing the first symbol that couldn't be parsed, or
+// an error if a symbol names an unknown transformation or a symbol or $repeat
+// key uses an undeclared namespace prefix
+//
+// Side Effects : Each parsed symbol is recorded in the Converter
+//
+// Abstract :
+// This method walks an object definition from the configuration file,
+// recursing into nested objects and arrays, and parses every find and replace
+// symbol found within a string so that symbols don't need to be parsed again
+// for each object that is converted.
 // -----------------------------------------------------------------------------
+func (c *Converter) parseSymbols(definition interface{}) error {
+
+	switch d := definition.(type) {
+	case *orderedMap:
+		repeat, ok := d.values["$repeat"].(string)
+
+		if ok {
+			err := c.addReference(repeat)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, v := range d.values {
+			err := c.parseSymbols(v)
+
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, v := range d {
+			err := c.parseSymbols(v)
+
+			if err != nil {
+				return err
+			}
+		}
+	case string:
+		for _, match := range findAndReplaceRegex.FindAllString(d, -1) {
+			symbol, err := ParseFindAndReplaceSymbol(match)
+
+			if err != nil {
+				return err
+			}
+
+			// Transformations are looked up now so that an unknown one can't
+			// stop a conversion part way through
+			for _, transformation := range symbol.Modifiers.Transformations() {
+				_, ok := LookupTransform(transformation.Name)
+
+				if !ok {
+					return fmt.Errorf("unknown transformation '%s' in %s", transformation.Name, match)
+				}
+			}
+
+			err = c.addReference(symbol.Name)
+
+			if err != nil {
+				return err
+			}
+
+			c.symbols[match] = symbol
+		}
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.addReference()
+// Input        : name - A string representing an xmlKey named by the configuration
+// Output       : err - An error if the xmlKey uses an undeclared namespace prefix
+// Side Effects : The xmlKey and each of its ancestors are recorded as referenced
+//
+// Abstract :
+// This method records that the configuration refers to an xmlKey. The
+// ancestors of the xmlKey are recorded as well, since the elements that
+// contain a referenced element are needed to reach it.
+//
+// Example: Patients.Patient.Address.City also references Patients,
+// Patients.Patient and Patients.Patient.Address
+// -----------------------------------------------------------------------------
+func (c *Converter) addReference(name string) error {
+
+	for _, segment := range strings.Split(name, ".") {
+		prefix, _, ok := strings.Cut(strings.TrimPrefix(segment, "@"), ":")
+
+		if ok && c.prefixes[prefix] == "" {
+			return fmt.Errorf("%s uses the namespace prefix '%s', which isn't declared in $namespaces", name, prefix)
+		}
+	}
+
+	for i, r := range name {
+		if r == '.' {
+			c.references[name[:i]] = true
+		}
+	}
+
+	c.references[name] = true
+
+	return nil
+}
 
 // -----------------------------------------------------------------------------
-// Function     : generateOutputObjectMap()
+// Method       : Converter.parseNamespaces()
 // Input        :
-// configMap - A map of strings to typeless values that represents a collection
-// of lists to populate along with the definitions of objects to be contained
-// within those collections
-// xmlKey - A string representing a location in the XML file hierarchy where we
-// want to look for an object definition
+// declarations - The typeless value of the configuration file's $namespaces
+// key, which maps each namespace prefix to the URI that it stands for
 //
-// Output       :
-// outputObjectMap - A map of strings to typeless values that represents the
-// definition of an object to be created and added to a collection of objects
+// Output       : err - An error describing why the declarations are invalid
+// Side Effects : The Converter begins matching elements by namespace
 //
+// Abstract :
+// This method records the namespace prefixes that a configuration file
+// declares. Each prefix must stand for a different URI, so that every element
+// has only one name that the configuration can refer to it by.
+//
+// Example: {"p": "urn:example:patients", "ext": "urn:example:extensions"}
+// -----------------------------------------------------------------------------
+func (c *Converter) parseNamespaces(declarations interface{}) error {
+
+	object, ok := declarations.(*orderedMap)
+
+	if !ok {
+		return errors.New("$namespaces must map each namespace prefix to a URI")
+	}
+
+	c.prefixes = make(map[string]string, len(object.keys))
+	c.namespaces = make(map[string]string, len(object.keys))
+
+	for _, prefix := range object.keys {
+		uri, ok := object.values[prefix].(string)
+
+		if !ok || uri == "" {
+			return fmt.Errorf("the namespace prefix '%s' must stand for a URI", prefix)
+		}
+
+		if !prefixRegex.MatchString(prefix) {
+			return fmt.Errorf("invalid namespace prefix '%s'", prefix)
+		}
+
+		other, ok := c.namespaces[uri]
+
+		if ok {
+			return fmt.Errorf("the namespace prefixes '%s' and '%s' both stand for %s", other, prefix, uri)
+		}
+
+		c.prefixes[prefix] = uri
+		c.namespaces[uri] = prefix
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.elementName()
+// Input        : name - The name of an XML element or attribute
+// Output       : A string representing the name as it's written in an xmlKey
 // Side Effects : none
 //
 // Abstract :
-// This function takes in the configuration file data and a key that specifies
-// which collection in the input XML for which we'd like to define its members
-// and then creates a map of strings to typeless values that represents the
-// definition of a single object to be added to that collection
+// This method names an element or attribute as the configuration refers to
+// it. When namespaces are ignored, or the name has no namespace, this is the
+// local name. Otherwise, the local name is given the prefix that the
+// configuration declares for its namespace. A namespace that the
+// configuration doesn't declare is written out in full in braces, so that its
+// elements never match a symbol.
+//
+// Example: For the element <hl7:MRN xmlns:hl7="urn:hl7"> and the declaration
+// {"ext": "urn:hl7"}, the name is 'ext:MRN'
 // -----------------------------------------------------------------------------
-func generateOutputObjectMap(configMap map[string]interface{}, xmlKey string) map[string]interface{} {
+func (c *Converter) elementName(name xml.Name) string {
 
-	// Create a map to represent the new output object
-	outputObjectMap := make(map[string]interface{})
+	if c.prefixes == nil || name.Space == "" {
+		return name.Local
+	}
 
-	// Split the input xmlKey into its tokens
-	// 0: Parent slice key, i.e. Patients
-	// 1: Object key, i.e. Patients.Patient
-	tokens := strings.Split(xmlKey, ".")
+	prefix, ok := c.namespaces[name.Space]
 
-	// Get the definition of this object type
-	parentKey := tokens[0]
-	objectMapConfig := configMap[parentKey].([]interface{})[0]
+	if !ok {
+		return "{" + name.Space + "}" + name.Local
+	}
+
+	return prefix + ":" + name.Local
+}
 
-	for k, v := range objectMapConfig.(map[string]interface{}) {
-		outputObjectMap[k] = v
+// -----------------------------------------------------------------------------
+// Method       : Converter.attributeNames()
+// Input        : t - An XML start element token
+// Output       : A list of the names of the element's attributes
+// Side Effects : none
+//
+// Abstract :
+// This method names each of an element's attributes as described by
+// elementName(), leaving out namespace declarations.
+// -----------------------------------------------------------------------------
+func (c *Converter) attributeNames(t xml.StartElement) []string {
+
+	names := make([]string, 0, len(t.Attr))
+
+	for _, a := range t.Attr {
+		if !isNamespaceDeclaration(a) {
+			names = append(names, c.elementName(a.Name))
+		}
 	}
 
-	return outputObjectMap
+	return names
 }
 
 // -----------------------------------------------------------------------------
-// Function     : getReplacementMapAndModifiers()
+// Method       : Converter.generateOutputObject()
 // Input        :
-// m - A map of strings to typeless values that represents the definition of an
-// object to be added to a collection of objects
+// definition - A typeless value taken from the configuration file that
+// represents the definition of an object to be added to a collection. The
+// definition may contain nested objects and arrays.
+// s - The scope against which find and replace symbols are resolved
 //
 // Output       :
-// findAndReplaceMap - A map of strings representing xmlKeys, or locations
-// within the input XML file's hierarchy, mapped to strings representing the
-// fields within an object's definition where we will find a find and replace
-// symbol for that XML key
-// modifierMap - A map of strings representing xmlKeys, or locations
-// within the input XML file's hierarchy, mapped to strings representing any
-// modifiers that were applied to find and replace symbols in the input
-// object definition
+// object - A typeless value with the same shape as the definition in which
+// every find and replace symbol has been replaced with its value
+// err - A *TransformError if a symbol's value couldn't be transformed
 //
 // Side Effects : none
 //
 // Abstract :
-// This function takes in a map representing the definition of an object to be
-// added to a collection of object and produces two different maps. The first,
-// the findAndReplaceMap, stores the locations of find and replace symbols to
-// be referenced as we're creating objects while iterating over the input XML.
-// The second, the modifierMap, stores any modifiers that were present within
-// the find and replace symbols specified in the input object definition.
+// This method walks an object definition from the configuration file,
+// recursing into nested objects and arrays, and produces a copy of it in which
+// the find and replace symbols within each string have been replaced. Symbols
+// for which no value was found are left in place. Values of any other type are
+// copied as they are. An object's fields keep the order of the definition.
+//
+// Repeated definitions, i.e. strings containing a symbol with the repeat
+// modifier and objects containing the $repeat key, produce a list of values.
+// Within an array, that list's values are added to the array in place of the
+// repeated definition. Anywhere else, the list replaces the definition.
 // -----------------------------------------------------------------------------
-func getReplacementMapAndModifiers(m map[string]interface{}) (map[string]string, map[string]map[string]string) {
+func (c *Converter) generateOutputObject(definition interface{}, s *scope) (interface{}, error) {
 
-	findAndReplaceRegex := regexp.MustCompile(FindAndReplaceExpression)
+	repeated, ok, err := c.generateRepeatedValues(definition, s)
+
+	if ok || err != nil {
+		return repeated, err
+	}
 
-	findAndReplaceMap := map[string]string{}
-	modifierMap := make(map[string]map[string]string)
+	switch d := definition.(type) {
+	case *orderedMap:
+		outputObjectMap := newOrderedMap(len(d.keys))
 
-	// For each field in the input map
-	for k, v := range m {
+		for _, k := range d.keys {
+			value, err := c.generateOutputObject(d.values[k], s)
 
-		switch v.(type) {
-		case string:
-		case float64:
-			// By default a straight assignment to v shadows the outer variable
-			// This allows the value string(v) to persist beyond this case block
-			f, ok := v.(float64)
+			if err != nil {
+				return nil, err
+			}
+
+			outputObjectMap.set(k, value)
+		}
+
+		return outputObjectMap, nil
+
+	case []interface{}:
+		outputList := []interface{}{}
+
+		for _, v := range d {
+			repeated, ok, err := c.generateRepeatedValues(v, s)
+
+			if err != nil {
+				return nil, err
+			}
 
 			if ok {
-				str := strconv.FormatFloat(f, 'f', -1, 64) // Convert JSON float to a string
-				vPtr := &v                                 // Get a reference to v
-				*vPtr = str                                // Assign the new string to v in a way that will persist beyond this block
+				outputList = append(outputList, repeated...)
+				continue
+			}
+
+			value, err := c.generateOutputObject(v, s)
+
+			if err != nil {
+				return nil, err
+			}
+
+			outputList = append(outputList, value)
+		}
+
+		return outputList, nil
+
+	case string:
+		return c.generateValue(d, s, -1)
+	}
+
+	return definition, nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.generateRepeatedValues()
+// Input        :
+// definition - A typeless value taken from the configuration file
+// s - The scope against which find and replace symbols are resolved
+//
+// Output       :
+// values - A list of typeless values generated for each repetition
+// ok - A boolean value representing whether the definition was repeated
+// err - A *TransformError if a symbol's value couldn't be transformed, or a
+// *MissingValueError if a repeated symbol or $repeat key that needs a value
+// matched nothing
+//
+// Side Effects : none
+//
+// Abstract :
+// This method generates one value for each repetition of a repeated
+// definition. A string is repeated when it contains a symbol with the repeat
+// modifier, and generates a value for each element found at that symbol's
+// xmlKey. An object is repeated when it contains the $repeat key, whose value
+// is the xmlKey of the repeated element. The object's remaining fields are
+// used as the definition of each repetition, with the repeated element as the
+// innermost scope. Nothing to repeat gives an empty list, except for a
+// repeated symbol with the required modifier, or in strict mode any repeated
+// symbol without a default and any $repeat key.
+//
+// Example: <Patients.Patient.Phone repeat> generates ["555-1234", "555-9876"]
+// for a patient with two phone numbers
+// -----------------------------------------------------------------------------
+func (c *Converter) generateRepeatedValues(definition interface{}, s *scope) ([]interface{}, bool, error) {
+
+	switch d := definition.(type) {
+	case *orderedMap:
+		repeat, ok := d.values["$repeat"].(string)
+
+		if !ok {
+			return nil, false, nil
+		}
+
+		// The remaining fields define each repetition
+		elementDefinition := newOrderedMap(len(d.keys))
+
+		for _, k := range d.keys {
+			if k != "$repeat" {
+				elementDefinition.set(k, d.values[k])
 			}
+		}
 
-		default:
-			fmt.Println("Unhandled configuration value type encountered:", k, v)
+		elements, _ := s.elements(repeat)
+
+		// In strict mode, a block that repeats nothing is missing its values
+		if len(elements) == 0 && c.Mode == ModeStrict {
+			return nil, false, &MissingValueError{Symbol: "$repeat " + repeat}
 		}
 
-		// Search for any replacement symbols
-		matches := findAndReplaceRegex.FindAllString(v.(string), -1)
+		values := make([]interface{}, len(elements))
+
+		for i, e := range elements {
+			value, err := c.generateOutputObject(elementDefinition, &scope{path: repeat, element: e, parent: s})
 
-		// Assign any discovered replacement symbols to the find and replace map
-		if matches != nil {
-			for _, match := range matches {
-				name, modifiers := ParseFindAndReplaceSymbol(match)
-				findAndReplaceMap[name] = k
-				modifierMap[name] = modifiers
+			if err != nil {
+				return nil, false, err
 			}
+
+			values[i] = value
 		}
 
+		return values, true, nil
+
+	case string:
+		// The number of repetitions is the largest number of values found
+		// for any of the repeated symbols in the string
+		count := -1
+
+		for _, match := range findAndReplaceRegex.FindAllString(d, -1) {
+			symbol := c.symbols[match]
+
+			if !symbol.Modifiers.Flag("repeat") {
+				continue
+			}
+
+			// A repeated symbol that matches nothing at all is missing,
+			// like any other symbol, unless it has a default
+			found := len(s.values(symbol.Name))
+			_, hasDefault := symbol.Modifiers.Get("default")
+
+			if found == 0 && !hasDefault && (symbol.Modifiers.Flag("required") || c.Mode == ModeStrict) {
+				return nil, false, &MissingValueError{Symbol: match}
+			}
+
+			count = max(count, found)
+		}
+
+		if count < 0 {
+			return nil, false, nil
+		}
+
+		values := make([]interface{}, count)
+
+		for i := range values {
+			value, err := c.generateValue(d, s, i)
+
+			if err != nil {
+				return nil, false, err
+			}
+
+			values[i] = value
+		}
+
+		return values, true, nil
 	}
 
-	return findAndReplaceMap, modifierMap
+	return nil, false, nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.generateValue()
+// Input        :
+// template - A string containing find and replace symbols
+// s - The scope against which find and replace symbols are resolved
+// repetition - An integer representing which of the values found for a
+// repeated symbol should be used, or -1 if the string isn't repeated
+//
+// Output       :
+// value - A typeless value representing the output value for the given template
+// err - A *TransformError if a symbol's value couldn't be transformed or
+// converted to its type, or a *MissingValueError if no value was found for it
+//
+// Side Effects : none
+//
+// Abstract :
+// This method produces the output value for a string taken from an object
+// definition. When the string consists of exactly one find and replace symbol,
+// the symbol's value is converted to the type named by its type modifier. If
+// there is no type modifier, the value takes the type produced by its last
+// transformation, see resultType(). Text taken directly from the XML becomes
+// a number or a boolean when it's written the way JSON writes one, so that
+// 39 is a number while 00123 remains a string.
+// In lenient mode, a single symbol that no value was found for is null.
+// Otherwise, each symbol is replaced within the string and the result is a
+// string.
+//
+// Example: <Patients.Patient.DateOfBirth transform=yearsElapsed> produces the
+// number 39 while "Age: <Patients.Patient.DateOfBirth transform=yearsElapsed>"
+// produces the string "Age: 39"
+// -----------------------------------------------------------------------------
+func (c *Converter) generateValue(template string, s *scope, repetition int) (interface{}, error) {
+
+	location := findAndReplaceRegex.FindStringIndex(template)
+
+	// If the template is anything other than a single symbol, the output is a string
+	if location == nil || location[0] != 0 || location[1] != len(template) {
+		return c.replaceSymbols(template, s, repetition)
+	}
+
+	symbol := c.symbols[template]
+	value, ok, err := c.resolveSymbol(template, s, repetition)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// In lenient mode, a symbol that no value was found for is null
+	if !ok && c.Mode == ModeLenient {
+		return nil, nil
+	}
+
+	if !ok {
+		return value, nil
+	}
+
+	// Without a type modifier, the value takes the type produced by its last
+	// transformation, or is typed automatically if it wasn't transformed
+	typeName, ok := symbol.Modifiers.Get("type")
+
+	if !ok {
+		typeName = resultType(symbol.Modifiers.Transformations())
+	}
+
+	typed, err := convertType(value, typeName)
+
+	if err != nil {
+		return nil, &TransformError{Symbol: template, Transform: "type=" + typeName, Value: value, Err: err}
+	}
+
+	return typed, nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.replaceSymbols()
+// Input        :
+// template - A string containing find and replace symbols
+// s - The scope against which find and replace symbols are resolved
+// repetition - An integer representing which of the values found for a
+// repeated symbol should be used, or -1 if the string isn't repeated
+//
+// Output       :
+// replaced - A string in which every find and replace symbol has been replaced
+// err - A *TransformError if a symbol's value couldn't be transformed
+//
+// Side Effects : none
+//
+// Abstract :
+// This method replaces each find and replace symbol in a string with its
+// value as determined by resolveSymbol().
+// -----------------------------------------------------------------------------
+func (c *Converter) replaceSymbols(template string, s *scope, repetition int) (string, error) {
+
+	var err error
+
+	replaced := findAndReplaceRegex.ReplaceAllStringFunc(template, func(symbol string) string {
+
+		// Once a symbol has failed, there's no need to fill in the rest
+		if err != nil {
+			return symbol
+		}
+
+		var value string
+		value, _, err = c.resolveSymbol(symbol, s, repetition)
+
+		return value
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return replaced, nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.resolveSymbol()
+// Input        :
+// text - A string representing a find and replace symbol
+// s - The scope against which the symbol is resolved
+// repetition - An integer representing which of the values found for a
+// repeated symbol should be used, or -1 if the string isn't repeated
+//
+// Output       :
+// value - A string representing the symbol's value
+// ok - A boolean value representing whether a value was found for the symbol
+// err - A *TransformError if the value couldn't be transformed, or a
+// *MissingValueError if a value was needed and none was found
+//
+// Side Effects : none
+//
+// Abstract :
+// This method finds the value at a symbol's xmlKey and applies any of the
+// symbol's modifiers to it. Symbols without the repeat modifier use the first
+// value that was found. The value's whitespace is handled first, as given by
+// the symbol's whitespace modifier or else the Converter's Whitespace policy.
+// If no value was found, or the value is empty, the
+// symbol's default modifier is used as its value when it has one, and a
+// symbol with the required modifier is an error. Otherwise, a repeated
+// symbol's value is empty, and any other symbol is left in place, replaced
+// with nothing in lenient mode or an error in strict mode.
+// -----------------------------------------------------------------------------
+func (c *Converter) resolveSymbol(text string, s *scope, repetition int) (string, bool, error) {
+
+	symbol := c.symbols[text]
+	values := s.values(symbol.Name)
+
+	// Use the value for this repetition if the symbol is repeated
+	repeated := symbol.Modifiers.Flag("repeat")
+	index := 0
+
+	if repeated && repetition >= 0 {
+		index = repetition
+	}
+
+	found := index < len(values)
+	raw := ""
+
+	if found {
+		policy := c.Whitespace
+		name, ok := symbol.Modifiers.Get("whitespace")
+
+		if ok {
+			policy = whitespacePolicies[name]
+		}
+
+		raw = policy.apply(values[index])
+	}
+
+	// Fall back to the symbol's default when there's no value to use
+	if !found || raw == "" {
+		defaultValue, ok := symbol.Modifiers.Get("default")
+
+		if ok {
+			return defaultValue, true, nil
+		}
+
+		if symbol.Modifiers.Flag("required") {
+			return "", false, &MissingValueError{Symbol: text}
+		}
+	}
+
+	if !found {
+		switch {
+		case repeated, c.Mode == ModeLenient:
+			return "", false, nil
+		case c.Mode == ModeStrict:
+			return "", false, &MissingValueError{Symbol: text}
+		}
+
+		return text, false, nil
+	}
+
+	value, err := applyModifiers(raw, symbol.Modifiers, c.AsOf)
+
+	if err != nil {
+		err.Symbol = text
+		return "", false, err
+	}
+
+	return value, true, nil
 }
 
 // -----------------------------------------------------------------------------
@@ -451,54 +1334,51 @@ func getReplacementMapAndModifiers(m map[string]interface{}) (map[string]string,
 // -----------------------------------------------------------------------------
 // UTILITY
 // -----------------------------------------------------------------------------
+
 // -----------------------------------------------------------------------------
-// Function     : intro()
-// Input        : none
-// Output       : none
-// Side Effects : Prints an introductory message to the console
+// Function     : isNamespaceDeclaration()
+// Input        : a - An XML attribute
+// Output       :
+// A boolean value representing whether the attribute declares a namespace,
+// e.g. xmlns="urn:example" or xmlns:p="urn:example"
+//
+// Side Effects : none
 //
 // Abstract :
-// This function introduces the application by printing a message to the screen
-//
-// TODO: Replace hardcoded version string with a constant to be replaced in
-// a deployment pipeline
-// -----------------------------------------------------------------------------
-func intro() {
-	// Introduction
-	fmt.Println()
-	fmt.Println("Welcome to the Gopher Hole v0.1.0!")
-	fmt.Println()
-	fmt.Println("Throw your XML into the hole, and the Gophers will toss back JSON!")
-	fmt.Println()
-
-	fmt.Println("+--------------------+")
-	fmt.Println("| Was that XML raw?  |")
-	fmt.Println("+--------------------+")
-	fmt.Println("  \\")
-	fmt.Println("   \\")
-	fmt.Println("    \\")
-	fmt.Println("         ,_---~~~~~----._         ")
-	fmt.Println("  _,,_,*^____      _____``*g*\"*, ")
-	fmt.Println(" / __/ /'     ^.  /      \\ ^@q   f ")
-	fmt.Println("[  @f | @))    |  | @))   l  0 _/  ")
-	fmt.Println(" \\`/   \\~____ / __ \\_____/    \\   ")
-	fmt.Println(" |           _l__l_           I   ")
-	fmt.Println(" }          [______]           I  ")
-	fmt.Println(" ]            | | |            |  ")
-	fmt.Println(" ]             ~ ~             |  ")
-	fmt.Println(" |                            |   ")
-	fmt.Println("  |                           |   ")
-	fmt.Println()
-	fmt.Println("Developed by Christian Westbrook ")
-	fmt.Println("https://github.com/christian-westbrook/")
-	fmt.Println()
-
-	fmt.Println("Artwork by belbomemo")
-	fmt.Println("https://gist.github.com/belbomemo")
-	fmt.Println()
+// This function picks out the attributes that declare namespaces, which the
+// XML decoder has already applied to element names.
+// -----------------------------------------------------------------------------
+func isNamespaceDeclaration(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns")
 }
 
 // -----------------------------------------------------------------------------
+// Method       : Whitespace.apply()
+// Input        : text - A string taken from the input XML
+// Output       : The text with its whitespace handled as the policy describes
+// Side Effects : none
+//
+// Abstract :
+// This method applies a whitespace policy to an element's text or an
+// attribute's value.
+// -----------------------------------------------------------------------------
+func (w Whitespace) apply(text string) string {
+
+	switch w {
+	case WhitespacePreserve:
+		return text
+	case WhitespaceTrim:
+		return strings.TrimSpace(text)
+	case WhitespaceCollapse:
+		return strings.Join(strings.Fields(text), " ")
+	}
+
+	if IsWhitespace(text) {
+		return ""
+	}
+
+	return text
+}
 
 // -----------------------------------------------------------------------------
 // Function     : IsWhitespace()
@@ -528,48 +1408,3 @@ func IsWhitespace(s string) bool {
 // -----------------------------------------------------------------------------
 
 // -----------------------------------------------------------------------------
-// Function     : ParseFindAndReplaceSymbol
-//
-// Input :
-// s - A string representing a find and replace symbol in the config.json file
-// Example: <Patients.Patient.DateOfBirth transform=yearsElapsed>
-//
-// Output :
-// name - A string representing the name of the given find and replace symbol
-// modifiers - A map of strings representing modifier names to strings
-// representing modifiers
-//
-// Side Effects : none
-//
-// Abstract :
-// This function takes in a find and replace symbol from the config.json file
-// and breaks it into a name and a map of modifiers.
-//
-// Example: For <Patients.Patient.DateOfBirth transform=yearsElapsed>, name
-// is 'Patients.Patient.DateOfBirth'
-//
-// Example: For <Patients.Patient.DateOfBirth transform=yearsElapsed>, modifiers
-// contains the key 'transform' mapped to the value 'yearsElapsed'
-// Note: In the case where there is no modifier in a find and replace symbol,
-// modifiers is a nil value
-// -----------------------------------------------------------------------------
-func ParseFindAndReplaceSymbol(s string) (string, map[string]string) {
-	tokens := strings.Split(s[1:len(s)-1], " ")
-	name := tokens[0]
-
-	// If there aren't any modifiers, return early
-	if len(tokens) == 1 {
-		return name, nil
-	}
-
-	modifiers := map[string]string{}
-
-	for _, t := range tokens[1:] {
-		tokens := strings.Split(t, "=")
-		modifiers[tokens[0]] = tokens[1]
-	}
-
-	return name, modifiers
-}
-
-// -----------------------------------------------------------------------------
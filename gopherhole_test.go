@@ -1,8 +1,14 @@
-package main
+package gopherhole
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIsWhitespace(t *testing.T) {
@@ -36,28 +42,104 @@ func TestIsWhitespace(t *testing.T) {
 func TestParseFindAndReplaceSymbol(t *testing.T) {
 
 	var tests = []struct {
-		input string
-		name  string
+		input     string
+		name      string
+		modifiers string
+		wantErr   bool
 	}{
-		{"<Patients>", "Patients"},
-		{"<DateOfBirth transform=yearsElapsed", "DateOfBirth"},
+		{"<Patients>", "Patients", "", false},
+		{"<DateOfBirth transform=yearsElapsed>", "DateOfBirth", "transform=yearsElapsed", false},
+		{"<DateOfBirth transform=yearsElapsed", "", "", true},
+		{"<Patients.Patient.Phone repeat>", "Patients.Patient.Phone", "repeat=true", false},
+		{"<Patients.Patient.Phone  repeat=false\ttype=int >", "Patients.Patient.Phone", "repeat=false type=int", false},
+		{`<Patients.Patient.MiddleName default="not given">`, "Patients.Patient.MiddleName", "default=not given", false},
+		{`<Patients.Patient.Note default="say \"hi\" <here>">`, "Patients.Patient.Note", `default=say "hi" <here>`, false},
+		{`<Patients.Patient.Visit transform=dateFormat(from="01/02/2006", to="2006-01-02")|trim>`, "Patients.Patient.Visit", `transform=dateFormat(from="01/02/2006", to="2006-01-02")|trim`, false},
+		{"<Patients.Patient_1.Home-Phone>", "Patients.Patient_1.Home-Phone", "", false},
+		{"<Patients.Patient.Phone.@Type default=home>", "Patients.Patient.Phone.@Type", "default=home", false},
+		{"<Patients.@p:Facility>", "Patients.@p:Facility", "", false},
+		{"<Patients.@Patient.Phone>", "", "", true},
+		{"<Patients.Patient.@>", "", "", true},
+		{"<Patients.Patient.Notes whitespace=collapse>", "Patients.Patient.Notes", "whitespace=collapse", false},
+		{"<Patients.Patient.Notes whitespace=squash>", "", "", true},
+		{"<>", "", "", true},
+		{"<Patients..Patient>", "", "", true},
+		{"<Patients.Patient.Name colour=red>", "", "", true},
+		{"<Patients.Patient.Name type=integer>", "", "", true},
+		{"<Patients.Patient.Name repeat=sometimes>", "", "", true},
+		{"<Patients.Patient.Name type=int type=string>", "", "", true},
+		{"<Patients.Patient.Name transform=>", "", "", true},
+		{"<Patients.Patient.Name transform=substring(0, 3>", "", "", true},
+		{"<Patients.Patient.Name transform=substring(0 3)>", "", "", true},
+		{"<Patients.Patient.Name transform=pad(width=1, width=2)>", "", "", true},
+		{`<Patients.Patient.Name default="unterminated>`, "", "", true},
+		{"<Patients.Patient.Name default=a=b>", "", "", true},
 	}
 
 	for _, test := range tests {
 		testName := fmt.Sprintf("%s", test.input)
 
 		t.Run(testName, func(t *testing.T) {
-			name, _ := ParseFindAndReplaceSymbol(test.input)
+			symbol, err := ParseFindAndReplaceSymbol(test.input)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Got no error, wanted an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %v, wanted none", err)
+			}
+
+			if symbol.Name != test.name {
+				t.Errorf("Got %s, wanted %s", symbol.Name, test.name)
+			}
+
+			modifiers := []string{}
 
-			if name != test.name {
-				t.Errorf("Got %s, wanted %s", name, test.name)
+			for _, modifier := range symbol.Modifiers {
+				modifiers = append(modifiers, modifier.Key+"="+modifier.Value)
+			}
+
+			got := strings.Join(modifiers, " ")
+
+			if got != test.modifiers {
+				t.Errorf("Got %s, wanted %s", got, test.modifiers)
 			}
 		})
 	}
 }
 
+func TestParseTransformationArguments(t *testing.T) {
+
+	symbol, err := ParseFindAndReplaceSymbol(`<Patients.Patient.Name transform=trim|pad(10, side="left", fill = "\\")|substring( 0 , 3 )>`)
+
+	if err != nil {
+		t.Fatalf("Got error %v, wanted none", err)
+	}
+
+	got := []string{}
+
+	for _, transformation := range symbol.Modifiers.Transformations() {
+		got = append(got, fmt.Sprintf("%s %v %v", transformation.Name, transformation.Args.Positional, transformation.Args.Named))
+	}
+
+	want := []string{"trim [] map[]", `pad [10] map[fill:\ side:left]`, "substring [0 3] map[]"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %q, wanted %q", got, want)
+	}
+}
+
 func TestYearsElapsed(t *testing.T) {
 
+	// Pin the clock so that the expected ages don't drift over time
+	now = func() time.Time { return time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
 	var tests = []struct {
 		input string
 		want  int
@@ -66,6 +148,7 @@ func TestYearsElapsed(t *testing.T) {
 		{"1985-10-25", 39},
 		{"2024-01-01", 1},
 		{"2025-01-01", 0},
+		{"unknown", 2024},
 	}
 
 	for _, test := range tests {
@@ -80,3 +163,662 @@ func TestYearsElapsed(t *testing.T) {
 		})
 	}
 }
+
+func TestConvert(t *testing.T) {
+
+	config := `{
+		"Patients": [
+			{
+				"id": "<Patients.Patient.ID>",
+				"name": "<Patients.Patient.FirstName> <Patients.Patient.LastName>"
+			}
+		]
+	}`
+
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+	<Patients>
+		<Patient ID="12345">
+			<FirstName>John</FirstName>
+			<LastName>Doe</LastName>
+		</Patient>
+	</Patients>`
+
+	want := `{"Patients":[{"id":12345,"name":"John Doe"}]}`
+	got := convertString(t, config, input, nil)
+
+	if got != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestNewConverterInvalidConfig(t *testing.T) {
+
+	_, err := NewConverter(strings.NewReader("{"))
+
+	if err == nil {
+		t.Errorf("Got no error, wanted an error for invalid configuration JSON")
+	}
+}
+
+func TestConvertNestedElements(t *testing.T) {
+
+	var tests = []struct {
+		name   string
+		config string
+		input  string
+		want   string
+	}{
+		{
+			"deep fields",
+			`{"Patients": [{"city": "<Patients.Patient.Address.City>", "zip": "<Patients.Patient.Address.Postal.Code>"}]}`,
+			`<Patients>
+				<Patient>
+					<Address>
+						<City>Springfield</City>
+						<Postal><Code>12345</Code></Postal>
+					</Address>
+				</Patient>
+			</Patients>`,
+			`{"Patients":[{"city":"Springfield","zip":12345}]}`,
+		},
+		{
+			"nested collection",
+			`{"Hospital.Ward.Patients": [{"id": "<Hospital.Ward.Patients.Patient.ID>", "name": "<Hospital.Ward.Patients.Patient.Name>"}]}`,
+			`<Hospital>
+				<Name>General</Name>
+				<Ward>
+					<Patients>
+						<Patient ID="1"><Name>John</Name></Patient>
+						<Patient ID="2"><Name>Jane</Name></Patient>
+					</Patients>
+				</Ward>
+			</Hospital>`,
+			`{"Hospital.Ward.Patients":[{"id":1,"name":"John"},{"id":2,"name":"Jane"}]}`,
+		},
+		{
+			"nested template",
+			`{"Patients": [{"name": "<Patients.Patient.Name>", "address": {"city": "<Patients.Patient.Address.City>", "geo": {"zip": "<Patients.Patient.Address.Zip>"}}, "phones": ["<Patients.Patient.Phone>", "tel:<Patients.Patient.Fax>"], "tags": [{"kind": "patient"}, 7, true, null]}]}`,
+			`<Patients>
+				<Patient>
+					<Name>John</Name>
+					<Address><City>Springfield</City><Zip>12345</Zip></Address>
+					<Phone>555-1234</Phone>
+					<Fax>555-9876</Fax>
+				</Patient>
+			</Patients>`,
+			`{"Patients":[{"name":"John","address":{"city":"Springfield","geo":{"zip":12345}},"phones":["555-1234","tel:555-9876"],"tags":[{"kind":"patient"},7,true,null]}]}`,
+		},
+		{
+			"repeated symbols",
+			`{"Patients": [{"name": "<Patients.Patient.Name>", "phones": "<Patients.Patient.Phone repeat>", "numbers": ["main", "tel:<Patients.Patient.Phone repeat>"], "faxes": "<Patients.Patient.Fax repeat>", "first": "<Patients.Patient.Phone>"}]}`,
+			`<Patients>
+				<Patient>
+					<Name>John</Name>
+					<Phone>555-1234</Phone>
+					<Phone>555-9876</Phone>
+				</Patient>
+			</Patients>`,
+			`{"Patients":[{"name":"John","phones":["555-1234","555-9876"],"numbers":["main","tel:555-1234","tel:555-9876"],"faxes":[],"first":"555-1234"}]}`,
+		},
+		{
+			"repeated definitions",
+			`{"Patients": [{"id": "<Patients.Patient.ID>", "allergies": [{"$repeat": "Patients.Patient.Allergies.Allergy", "patient": "<Patients.Patient.ID>", "substance": "<Patients.Patient.Allergies.Allergy.Substance>", "reactions": "<Patients.Patient.Allergies.Allergy.Reaction repeat>"}]}]}`,
+			`<Patients>
+				<Patient ID="1">
+					<Allergies>
+						<Allergy>
+							<Substance>Peanuts</Substance>
+							<Reaction>Hives</Reaction>
+							<Reaction>Swelling</Reaction>
+						</Allergy>
+						<Allergy>
+							<Substance>Penicillin</Substance>
+						</Allergy>
+					</Allergies>
+				</Patient>
+				<Patient ID="2"></Patient>
+			</Patients>`,
+			`{"Patients":[{"id":1,"allergies":[{"patient":1,"substance":"Peanuts","reactions":["Hives","Swelling"]},{"patient":1,"substance":"Penicillin","reactions":[]}]},{"id":2,"allergies":[]}]}`,
+		},
+		{
+			"unconfigured elements",
+			`{"Patients": [{"name": "<Patients.Patient.Name>"}]}`,
+			`<Export>
+				<Doctors><Doctor><Name>Ada</Name></Doctor></Doctors>
+			</Export>
+			<Patients><Patient><Name>John</Name></Patient></Patients>`,
+			`{"Patients":[{"name":"John"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := convertString(t, test.config, test.input, nil)
+
+			if got != test.want {
+				t.Errorf("Got %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
+
+// convertString converts an input with a config, letting setup adjust the
+// converter first unless it's nil, and returns the output without indentation
+func convertString(t *testing.T, config string, input string, setup func(*Converter)) string {
+
+	t.Helper()
+
+	converter, err := NewConverter(strings.NewReader(config))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	if setup != nil {
+		setup(converter)
+	}
+
+	var output bytes.Buffer
+	err = converter.Convert(strings.NewReader(input), &output)
+
+	if err != nil {
+		t.Fatalf("Got error %v converting the input", err)
+	}
+
+	return compactJSON(t, output.String())
+}
+
+// compactJSON strips the indentation from converted output for comparison
+func compactJSON(t *testing.T, s string) string {
+
+	var buffer bytes.Buffer
+	err := json.Compact(&buffer, []byte(s))
+
+	if err != nil {
+		t.Fatalf("Got invalid JSON %s: %v", s, err)
+	}
+
+	return buffer.String()
+}
+
+func TestNewConverterInvalidCollection(t *testing.T) {
+
+	var tests = []string{
+		`{"Patients": "<Patients.Patient.Name>"}`,
+		`{"Patients": []}`,
+		`{}`,
+		`{"Patients..Patient": [{}]}`,
+		`{"Patients": ["<Patients.Patient.Name>"]}`,
+		`{"Patients": [{"name": "<Patients.Patient.Name>"}, {}]}`,
+		`{"Patients": [{"phones": {"$repeat": 42, "number": "<Patients.Patient.Phone>"}}]}`,
+		`{"Patients": [{"phones": {"$repeat": "Staff.Doctor.Phone"}}]}`,
+		`{"Patients": [{"name": "Dr. <Staff.Doctor.Name>"}]}`,
+		`{"Patients": [{"name": "<Patients.Patient.Name"}]}`,
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			_, err := NewConverter(strings.NewReader(test))
+
+			var configErr *ConfigError
+
+			if !errors.As(err, &configErr) {
+				t.Errorf("Got error %v, wanted a *ConfigError for an invalid collection", err)
+			}
+		})
+	}
+}
+
+func TestConvertType(t *testing.T) {
+
+	var tests = []struct {
+		value    string
+		typeName string
+		want     interface{}
+		wantErr  bool
+	}{
+		{"39", "", json.Number("39"), false},
+		{"-1.5e3", "", json.Number("-1.5e3"), false},
+		{"00123", "", "00123", false},
+		{"true", "", true, false},
+		{"John", "", "John", false},
+		{"00123", "string", "00123", false},
+		{" 42 ", "int", int64(42), false},
+		{"4.2", "int", nil, true},
+		{"4.2", "float", 4.2, false},
+		{"NaN", "float", nil, true},
+		{"false", "bool", false, false},
+		{"", "int", nil, false},
+		{"", "nullIfEmpty", nil, false},
+		{"John", "nullIfEmpty", "John", false},
+		{"John", "unknown", nil, true},
+	}
+
+	for _, test := range tests {
+		testName := fmt.Sprintf("%s %s", test.value, test.typeName)
+
+		t.Run(testName, func(t *testing.T) {
+			got, err := convertType(test.value, test.typeName)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Got %#v, wanted an error", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %v, wanted %#v", err, test.want)
+			}
+
+			if got != test.want {
+				t.Errorf("Got %#v, wanted %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertTypedValues(t *testing.T) {
+
+	// Pin the clock so that the expected ages don't drift over time
+	now = func() time.Time { return time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	config := `{
+		"Patients": [
+			{
+				"id": "<Patients.Patient.ID>",
+				"mrn": "<Patients.Patient.ID type=int>",
+				"age": "<Patients.Patient.DateOfBirth transform=yearsElapsed>",
+				"label": "Age <Patients.Patient.DateOfBirth transform=yearsElapsed>",
+				"weight": "<Patients.Patient.Weight type=float>",
+				"active": "<Patients.Patient.Active type=bool>",
+				"note": "<Patients.Patient.Note type=nullIfEmpty>",
+				"scores": "<Patients.Patient.Score repeat type=int>",
+				"version": 2,
+				"serial": 12345678901234567890,
+				"dose": 1.50
+			}
+		]
+	}`
+
+	input := `<Patients>
+		<Patient ID="00123">
+			<DateOfBirth>1985-07-15</DateOfBirth>
+			<Weight>81.5</Weight>
+			<Active>true</Active>
+			<Note></Note>
+			<Score>7</Score>
+			<Score>9</Score>
+		</Patient>
+	</Patients>`
+
+	want := `{"Patients":[{"id":"00123","mrn":123,"age":39,"label":"Age 39","weight":81.5,"active":true,"note":null,"scores":[7,9],"version":2,"serial":12345678901234567890,"dose":1.50}]}`
+
+	got := convertString(t, config, input, nil)
+
+	if got != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestConvertAsOf(t *testing.T) {
+
+	config := `{
+		"Patients": [
+			{
+				"age": "<Patients.Patient.DateOfBirth transform=yearsElapsed>",
+				"months": "<Patients.Patient.DateOfBirth transform=monthsElapsed>",
+				"born": "<Patients.Patient.DateOfBirth transform=dateFormat(\"January 2, 2006\")>"
+			}
+		]
+	}`
+
+	input := `<Patients><Patient><DateOfBirth>1985-07-15</DateOfBirth></Patient></Patients>`
+	want := `{"Patients":[{"age":30,"months":366,"born":"July 15, 1985"}]}`
+
+	got := convertString(t, config, input, func(c *Converter) {
+		c.AsOf = time.Date(2016, time.January, 20, 0, 0, 0, 0, time.UTC)
+	})
+
+	if got != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestModifiersTransformations(t *testing.T) {
+
+	var tests = []struct {
+		input string
+		want  string
+	}{
+		{"<Patients.Patient.Name>", ""},
+		{"<Patients.Patient.Name repeat>", ""},
+		{"<Patients.Patient.Name transform=trim>", "trim"},
+		{"<Patients.Patient.Name transform=trim|upper>", "trim upper"},
+		{"<Patients.Patient.Name transform=trim transform=upper>", "trim upper"},
+		{"<Patients.Patient.Name transform=trim|upper repeat transform=title>", "trim upper title"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			symbol, err := ParseFindAndReplaceSymbol(test.input)
+
+			if err != nil {
+				t.Fatalf("Got error %v, wanted none", err)
+			}
+
+			names := []string{}
+
+			for _, transformation := range symbol.Modifiers.Transformations() {
+				names = append(names, transformation.Name)
+			}
+
+			got := strings.Join(names, " ")
+
+			if got != test.want {
+				t.Errorf("Got %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewConverterInvalidSymbol(t *testing.T) {
+
+	config := `{"Patients": [{"name": {"first": "<Patients.Patient.FirstName transform=trim(>"}}]}`
+
+	_, err := NewConverter(strings.NewReader(config))
+
+	var symbolErr *SymbolError
+
+	if !errors.As(err, &symbolErr) {
+		t.Fatalf("Got %v, wanted a *SymbolError", err)
+	}
+
+	if symbolErr.Symbol != "<Patients.Patient.FirstName transform=trim(>" {
+		t.Errorf("Got symbol %s, wanted the malformed symbol", symbolErr.Symbol)
+	}
+}
+
+func TestConvertDefaults(t *testing.T) {
+
+	config := `{
+		"Patients": [
+			{
+				"middle": "<Patients.Patient.MiddleName default=\"not given\">",
+				"suffix": "<Patients.Patient.Suffix default=none>",
+				"visits": "<Patients.Patient.Visits type=int default=0>",
+				"label": "<Patients.Patient.FirstName> <Patients.Patient.MiddleName default=\"-\">"
+			}
+		]
+	}`
+
+	input := `<Patients><Patient><FirstName>John</FirstName><Suffix></Suffix></Patient></Patients>`
+	want := `{"Patients":[{"middle":"not given","suffix":"none","visits":0,"label":"John -"}]}`
+
+	got := convertString(t, config, input, nil)
+
+	if got != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestConvertModes(t *testing.T) {
+
+	config := `{
+		"Patients": [
+			{
+				"first": "<Patients.Patient.FirstName>",
+				"middle": "<Patients.Patient.MiddleName>",
+				"suffix": "<Patients.Patient.Suffix default=none>",
+				"label": "<Patients.Patient.FirstName> <Patients.Patient.MiddleName>"
+			}
+		]
+	}`
+
+	var tests = []struct {
+		name string
+		mode Mode
+		want string
+	}{
+		{"default", ModeDefault, `{"Patients":[{"first":"John","middle":"\u003cPatients.Patient.MiddleName\u003e","suffix":"none","label":"John \u003cPatients.Patient.MiddleName\u003e"}]}`},
+		{"lenient", ModeLenient, `{"Patients":[{"first":"John","middle":null,"suffix":"none","label":"John "}]}`},
+	}
+
+	input := `<Patients><Patient><FirstName>John</FirstName></Patient></Patients>`
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := convertString(t, config, input, func(c *Converter) {
+				c.Mode = test.mode
+			})
+
+			if got != test.want {
+				t.Errorf("Got %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertStrictObjects(t *testing.T) {
+
+	var tests = []struct {
+		name   string
+		config string
+		input  string
+		want   string
+	}{
+		{"literal template", `{"Patients": [{"x": "lit"}]}`, `<Patients><Patient/></Patients>`, `{"Patients":[{"x":"lit"}]}`},
+		{"collection attribute", `{"Patients": [{"f": "<Patients.@F>"}]}`, `<Patients F="1"><Patient/></Patients>`, `{"Patients":[{"f":1}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := convertString(t, test.config, test.input, func(c *Converter) {
+				c.Mode = ModeStrict
+			})
+
+			if got != test.want {
+				t.Errorf("Got %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertRepeatedSymbols(t *testing.T) {
+
+	config := `{
+		"Patients": [
+			{
+				"id": "<Patients.Patient.ID>",
+				"url": "https://example.com/patients/<Patients.Patient.ID>",
+				"key": "<Patients.Patient.ID>-<Patients.Patient.LastName>-<Patients.Patient.ID>",
+				"ids": ["<Patients.Patient.ID type=string>", "<Patients.Patient.ID type=int>"],
+				"label": "<Patients.Patient.LastName transform=upper>, <Patients.Patient.LastName>"
+			}
+		]
+	}`
+
+	input := `<Patients><Patient ID="42"><LastName>Doe</LastName></Patient></Patients>`
+	want := `{"Patients":[{"id":42,"url":"https://example.com/patients/42","key":"42-Doe-42","ids":["42",42],"label":"DOE, Doe"}]}`
+
+	got := convertString(t, config, input, nil)
+
+	if got != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestConvertNamespaces(t *testing.T) {
+
+	input := `<p:Patients xmlns:p="urn:example:patients" xmlns:ext="urn:example:extensions">
+		<p:Patient>
+			<p:ID>1</p:ID>
+			<ext:ID>A-1</ext:ID>
+			<Notes xmlns="urn:example:notes">Allergic to penicillin</Notes>
+			<Name>John Doe</Name>
+		</p:Patient>
+	</p:Patients>`
+
+	var tests = []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"ignored", `{"Patients": [{"id": "<Patients.Patient.ID>", "name": "<Patients.Patient.Name>"}]}`, `{"Patients":[{"id":1,"name":"John Doe"}]}`},
+		{"prefixes", `{"$namespaces": {"pat": "urn:example:patients", "x": "urn:example:extensions"}, "pat:Patients": [{"id": "<pat:Patients.pat:Patient.pat:ID>", "mrn": "<pat:Patients.pat:Patient.x:ID>", "name": "<pat:Patients.pat:Patient.Name>"}]}`, `{"pat:Patients":[{"id":1,"mrn":"A-1","name":"John Doe"}]}`},
+		{"default namespace", `{"$namespaces": {"p": "urn:example:patients", "n": "urn:example:notes"}, "p:Patients": [{"notes": "<p:Patients.p:Patient.n:Notes>", "other": "<p:Patients.p:Patient.Notes default=none>"}]}`, `{"p:Patients":[{"notes":"Allergic to penicillin","other":"none"}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := convertString(t, test.config, input, nil)
+
+			if got != test.want {
+				t.Errorf("Got %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewConverterInvalidNamespaces(t *testing.T) {
+
+	var tests = []struct {
+		name   string
+		config string
+	}{
+		{"undeclared prefix", `{"$namespaces": {"p": "urn:example:patients"}, "p:Patients": [{"id": "<p:Patients.p:Patient.ext:ID>"}]}`},
+		{"undeclared collection prefix", `{"p:Patients": [{"id": "<p:Patients.p:Patient.ID>"}]}`},
+		{"shared URI", `{"$namespaces": {"p": "urn:a", "q": "urn:a"}, "p:Patients": [{}]}`},
+		{"not an object", `{"$namespaces": "urn:a", "Patients": [{}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewConverter(strings.NewReader(test.config))
+
+			var configErr *ConfigError
+
+			if !errors.As(err, &configErr) {
+				t.Errorf("Got error %v, wanted a *ConfigError", err)
+			}
+		})
+	}
+}
+
+func TestConvertAttributes(t *testing.T) {
+
+	config := `{
+		"Hospital.Patients": [
+			{
+				"facility": "<Hospital.Patients.@Facility>",
+				"id": "<Hospital.Patients.Patient.@ID>",
+				"legacyID": "<Hospital.Patients.Patient.ID>",
+				"phones": [{"$repeat": "Hospital.Patients.Patient.Phone", "type": "<Hospital.Patients.Patient.Phone.@Type default=unknown>", "number": "<Hospital.Patients.Patient.Phone>"}],
+				"kinds": ["<Hospital.Patients.Patient.Phone.@Type repeat>"],
+				"ward": "<Hospital.Patients.Patient.Ward.@Name default=none>"
+			}
+		]
+	}`
+
+	input := `<Hospital Name="General">
+		<Patients Facility="North">
+			<Patient ID="1"><Phone Type="home">555-1234</Phone><Phone>555-9876</Phone><Phone Type="work">555-0000</Phone></Patient>
+			<Patient ID="2"><Ward Name="B"/></Patient>
+		</Patients>
+	</Hospital>`
+
+	want := `{"Hospital.Patients":[` +
+		`{"facility":"North","id":1,"legacyID":1,"phones":[{"type":"home","number":"555-1234"},{"type":"unknown","number":"555-9876"},{"type":"work","number":"555-0000"}],"kinds":["home","work"],"ward":"none"},` +
+		`{"facility":"North","id":2,"legacyID":2,"phones":[],"kinds":[],"ward":"B"}]}`
+
+	got := convertString(t, config, input, nil)
+
+	if got != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestConvertText(t *testing.T) {
+
+	config := `{
+		"Patients": [
+			{
+				"name": "<Patients.Patient.Name>",
+				"raw": "<Patients.Patient.Name whitespace=preserve>",
+				"note": "<Patients.Patient.Note>",
+				"blank": "<Patients.Patient.Blank default=none>"
+			}
+		]
+	}`
+
+	input := `<Patients>
+		<Patient>
+			<Name>  John <!-- middle name withheld -->  Doe  </Name>
+			<Note><![CDATA[Patient's]]> allergies &apos;noted&apos;<?review?></Note>
+			<Blank>   </Blank>
+		</Patient>
+	</Patients>`
+
+	var tests = []struct {
+		name       string
+		whitespace Whitespace
+		want       string
+	}{
+		{"default", WhitespaceDefault, `{"Patients":[{"name":"  John   Doe  ","raw":"  John   Doe  ","note":"Patient's allergies 'noted'","blank":"none"}]}`},
+		{"preserve", WhitespacePreserve, `{"Patients":[{"name":"  John   Doe  ","raw":"  John   Doe  ","note":"Patient's allergies 'noted'","blank":"   "}]}`},
+		{"trim", WhitespaceTrim, `{"Patients":[{"name":"John   Doe","raw":"  John   Doe  ","note":"Patient's allergies 'noted'","blank":"none"}]}`},
+		{"collapse", WhitespaceCollapse, `{"Patients":[{"name":"John Doe","raw":"  John   Doe  ","note":"Patient's allergies 'noted'","blank":"none"}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := convertString(t, config, input, func(c *Converter) {
+				c.Whitespace = test.whitespace
+			})
+
+			if got != test.want {
+				t.Errorf("Got %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertTransformedTypes(t *testing.T) {
+
+	now = func() time.Time { return time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	var tests = []struct {
+		symbol string
+		want   string
+	}{
+		{"<Patients.Patient.Weight>", `81`},
+		{"<Patients.Patient.Zip>", `"00123"`},
+		{"<Patients.Patient.Weight type=string>", `"81"`},
+		{"<Patients.Patient.ID transform=trim>", `"12345"`},
+		{"<Patients.Patient.Active transform=lower>", `"true"`},
+		{"<Patients.Patient.Active transform=trim|lower>", `"true"`},
+		{"<Patients.Patient.ID transform=trim type=int>", `12345`},
+		{"<Patients.Patient.Active transform=lower type=bool>", `true`},
+		{"<Patients.Patient.DateOfBirth transform=yearsElapsed>", `39`},
+		{"<Patients.Patient.DateOfBirth transform=yearsElapsed|trim>", `"39"`},
+		{"<Patients.Patient.DateOfBirth transform=datePart(month)>", `7`},
+		{"<Patients.Patient.DateOfBirth transform=datePart(monthName)>", `"July"`},
+	}
+
+	input := `<Patients><Patient><ID> 12345 </ID><Weight>81</Weight><Zip>00123</Zip><Active>TRUE</Active><DateOfBirth>1985-07-15</DateOfBirth></Patient></Patients>`
+
+	for _, test := range tests {
+		t.Run(test.symbol, func(t *testing.T) {
+			config, _ := json.Marshal(map[string]interface{}{"Patients": []interface{}{map[string]string{"value": test.symbol}}})
+			got := convertString(t, string(config), input, nil)
+			want := `{"Patients":[{"value":` + test.want + `}]}`
+
+			if got != want {
+				t.Errorf("Got %s, wanted %s", got, want)
+			}
+		})
+	}
+}
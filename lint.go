@@ -0,0 +1,289 @@
+// -----------------------------------------------------------------------------
+// File     : lint.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains the coverage report that describes how well a
+// configuration file matches a sample of XML. Rather than converting the XML,
+// a Converter records which of its symbols were given values, which XML
+// elements and attributes its configuration never refers to, and how many
+// objects each collection contains.
+//
+// Example:
+// report := converter.NewReport()
+// err = converter.Lint(xmlReader, report)
+// fmt.Println(report.Unmatched())
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"io"
+	"slices"
+	"sort"
+)
+
+// -----------------------------------------------------------------------------
+// COVERAGE
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : Report
+//
+// Abstract :
+// A Report describes how well a configuration file matches the XML inputs
+// that have been linted with it. Every collection and symbol in the
+// configuration file is listed, even if it's never found. A symbol matches an
+// object if the object contains an element or attribute at the symbol's
+// xmlKey, whether or not that value is empty.
+//
+// Example: After linting two patients, one of whom has a middle name,
+// Objects["Patients"] is 2 and Symbols["<Patients.Patient.MiddleName>"] is 1
+// -----------------------------------------------------------------------------
+type Report struct {
+	Objects      map[string]int // The number of objects found in each collection
+	Symbols      map[string]int // The number of objects that each symbol matched
+	Unreferenced map[string]int // The number of times that each xmlKey the configuration doesn't refer to was found
+
+	definitionSymbols map[string][]string // The symbols within each collection's definition
+	symbolNames       map[string]string   // The xmlKey named by each symbol
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.NewReport()
+// Input        : none
+// Output       : An empty Report listing the Converter's collections and symbols
+// Side Effects : none
+//
+// Abstract :
+// This method creates a Report that XML inputs can be linted into using
+// Converter.Lint. A Report can gather several inputs, so that a feed spread
+// across many files can be described as a whole.
+// -----------------------------------------------------------------------------
+func (c *Converter) NewReport() *Report {
+
+	report := &Report{
+		Objects:           make(map[string]int, len(c.configMap)),
+		Symbols:           make(map[string]int, len(c.symbols)),
+		Unreferenced:      make(map[string]int),
+		definitionSymbols: make(map[string][]string, len(c.configMap)),
+		symbolNames:       make(map[string]string, len(c.symbols)),
+	}
+
+	for k, v := range c.configMap {
+		report.Objects[k] = 0
+		report.definitionSymbols[k] = collectSymbols(v.([]interface{})[0], nil)
+	}
+
+	for text, symbol := range c.symbols {
+		report.Symbols[text] = 0
+		report.symbolNames[text] = symbol.Name
+	}
+
+	return report
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.Lint()
+// Input        :
+// r - A reader supplying sample XML data
+// report - The Report to record the XML data in
+//
+// Output       :
+// err - A *SyntaxError for malformed XML or an *InputError if the XML couldn't
+// be read
+//
+// Side Effects : The XML data is recorded in the report
+//
+// Abstract :
+// This method reads XML data as Converter.Convert would, but records how well
+// it matches the configuration file instead of converting it. Since no output
+// is generated, transformations aren't applied and the Converter's Mode has
+// no effect.
+// -----------------------------------------------------------------------------
+func (c *Converter) Lint(r io.Reader, report *Report) error {
+	return c.convert(r, report, report)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Report.Matched()
+// Input        : none
+// Output       : A sorted list of the symbols that matched at least one object
+// Side Effects : none
+//
+// Abstract :
+// This method lists the symbols that were given a value at least once.
+// -----------------------------------------------------------------------------
+func (r *Report) Matched() []string {
+
+	matched := []string{}
+
+	for text, count := range r.Symbols {
+		if count > 0 {
+			matched = append(matched, text)
+		}
+	}
+
+	sort.Strings(matched)
+
+	return matched
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Report.Unmatched()
+// Input        : none
+// Output       : A sorted list of the symbols that never matched an object
+// Side Effects : none
+//
+// Abstract :
+// This method lists the symbols that were never given a value, which usually
+// point to a misspelled element name or a field that the feed doesn't supply.
+// -----------------------------------------------------------------------------
+func (r *Report) Unmatched() []string {
+
+	unmatched := []string{}
+
+	for text, count := range r.Symbols {
+		if count == 0 {
+			unmatched = append(unmatched, text)
+		}
+	}
+
+	sort.Strings(unmatched)
+
+	return unmatched
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Report.addElement()
+// Input        :
+// xmlKey - A string representing the xmlKey of an element
+// referenced - A boolean value representing whether the element itself is
+// accounted for by the configuration
+// attributes - The names of the element's attributes
+// references - The xmlKeys that the configuration refers to
+//
+// Output       : none
+// Side Effects : The element and its attributes are recorded if unreferenced
+//
+// Abstract :
+// This method records an element, and each of its attributes, that the
+// configuration doesn't refer to. Attributes are recorded with an '@', and
+// are referenced whether or not the configuration names them with one.
+//
+// Example: <Patient Nickname="JJ"> records Patients.Patient.@Nickname unless
+// a symbol names it
+// -----------------------------------------------------------------------------
+func (r *Report) addElement(xmlKey string, referenced bool, attributes []string, references map[string]bool) {
+
+	if !referenced {
+		r.Unreferenced[xmlKey]++
+	}
+
+	for _, name := range attributes {
+		attribute := xmlKey + ".@" + name
+
+		if !references[attribute] && !references[xmlKey+"."+name] {
+			r.Unreferenced[attribute]++
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Report.addObject()
+// Input        :
+// collection - A string representing the collection key of the object
+// s - The scope of the object's element
+//
+// Output       : none
+// Side Effects : The object and the symbols that it matched are recorded
+//
+// Abstract :
+// This method counts an object toward its collection, and counts it toward
+// each symbol in the collection's definition that it has a value for.
+// -----------------------------------------------------------------------------
+func (r *Report) addObject(collection string, s *scope) {
+
+	r.Objects[collection]++
+
+	for _, text := range r.definitionSymbols[collection] {
+		if len(s.values(r.symbolNames[text])) > 0 {
+			r.Symbols[text]++
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Report.writeObject()
+// Input        :
+// collection - A string representing the name of a collection
+// object - An output object, which is always nil while linting
+//
+// Output       : nil
+// Side Effects : none
+//
+// Abstract :
+// This method allows a Report to stand in for the objectWriter of a
+// conversion. Objects are recorded by addObject instead.
+// -----------------------------------------------------------------------------
+func (r *Report) writeObject(collection string, object interface{}) error {
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Report.close()
+// Input        : none
+// Output       : nil
+// Side Effects : none
+//
+// Abstract :
+// This method allows a Report to stand in for the objectWriter of a
+// conversion. There is nothing to close.
+// -----------------------------------------------------------------------------
+func (r *Report) close() error {
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// UTILITY
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : collectSymbols()
+// Input        :
+// definition - A typeless value taken from the configuration file
+// symbols - The list of symbols found so far
+//
+// Output       : The list of symbols found so far, along with each distinct
+// symbol found within the definition
+//
+// Side Effects : none
+//
+// Abstract :
+// This function walks an object definition, recursing into nested objects and
+// arrays, and lists the find and replace symbols within its strings.
+// -----------------------------------------------------------------------------
+func collectSymbols(definition interface{}, symbols []string) []string {
+
+	switch d := definition.(type) {
+	case *orderedMap:
+		for _, k := range d.keys {
+			symbols = collectSymbols(d.values[k], symbols)
+		}
+	case []interface{}:
+		for _, v := range d {
+			symbols = collectSymbols(v, symbols)
+		}
+	case string:
+		for _, match := range findAndReplaceRegex.FindAllString(d, -1) {
+			if !slices.Contains(symbols, match) {
+				symbols = append(symbols, match)
+			}
+		}
+	}
+
+	return symbols
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,86 @@
+package gopherhole
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+
+	config := `{
+		"Hospital.Patients": [
+			{
+				"id": "<Hospital.Patients.Patient.ID>",
+				"facility": "<Hospital.Patients.@Facility>",
+				"name": "<Hospital.Patients.Patient.FirstName> <Hospital.Patients.Patient.MiddleName>",
+				"first": "<Hospital.Patients.Patient.FirstName>",
+				"phones": [{"$repeat": "Hospital.Patients.Patient.Phone", "number": "<Hospital.Patients.Patient.Phone.Number>"}],
+				"fax": "<Hospital.Patients.Patient.Fax transform=trim>"
+			}
+		],
+		"Hospital.Staff": [{"name": "<Hospital.Staff.Doctor.Name>"}]
+	}`
+
+	inputs := []string{
+		`<Hospital>
+			<Patients Facility="North">
+				<Patient ID="1" Nickname="JJ"><FirstName>John</FirstName><Phone><Number>555</Number><Kind>home</Kind></Phone></Patient>
+				<Patient ID="2"><FirstName>Jane</FirstName><MiddleName></MiddleName></Patient>
+			</Patients>
+			<Ward/>
+		</Hospital>`,
+		`<Hospital><Patients><Patient ID="3"><Phone><Kind>work</Kind></Phone></Patient></Patients></Hospital>`,
+	}
+
+	converter, err := NewConverter(strings.NewReader(config))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	report := converter.NewReport()
+
+	for _, input := range inputs {
+		err = converter.Lint(strings.NewReader(input), report)
+
+		if err != nil {
+			t.Fatalf("Got error %v linting the input", err)
+		}
+	}
+
+	wantObjects := map[string]int{"Hospital.Patients": 3, "Hospital.Staff": 0}
+	wantMatched := []string{
+		"<Hospital.Patients.@Facility>",
+		"<Hospital.Patients.Patient.FirstName>",
+		"<Hospital.Patients.Patient.ID>",
+		"<Hospital.Patients.Patient.MiddleName>",
+		"<Hospital.Patients.Patient.Phone.Number>",
+	}
+	wantUnmatched := []string{"<Hospital.Patients.Patient.Fax transform=trim>", "<Hospital.Staff.Doctor.Name>"}
+	wantUnreferenced := map[string]int{
+		"Hospital.Patients.Patient.@Nickname":  1,
+		"Hospital.Patients.Patient.Phone.Kind": 2,
+		"Hospital.Ward":                        1,
+	}
+
+	if !reflect.DeepEqual(report.Objects, wantObjects) {
+		t.Errorf("Got objects %v, wanted %v", report.Objects, wantObjects)
+	}
+
+	if report.Symbols["<Hospital.Patients.Patient.FirstName>"] != 2 {
+		t.Errorf("Got %d matches for the first name, wanted 2", report.Symbols["<Hospital.Patients.Patient.FirstName>"])
+	}
+
+	if got := report.Matched(); !reflect.DeepEqual(got, wantMatched) {
+		t.Errorf("Got matched %v, wanted %v", got, wantMatched)
+	}
+
+	if got := report.Unmatched(); !reflect.DeepEqual(got, wantUnmatched) {
+		t.Errorf("Got unmatched %v, wanted %v", got, wantUnmatched)
+	}
+
+	if !reflect.DeepEqual(report.Unreferenced, wantUnreferenced) {
+		t.Errorf("Got unreferenced %v, wanted %v", report.Unreferenced, wantUnreferenced)
+	}
+}
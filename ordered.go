@@ -0,0 +1,218 @@
+// -----------------------------------------------------------------------------
+// File     : ordered.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains the JSON object type that gopherhole uses for object
+// definitions and output objects. Unlike a Go map, it remembers the order in
+// which its keys were written, so that output objects list their fields in
+// the same order as the configuration file that defined them.
+//
+// Example: The definition {"name": ..., "id": ...} produces objects whose
+// name field comes before their id field
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// -----------------------------------------------------------------------------
+// ORDERED OBJECTS
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : orderedMap
+//
+// Abstract :
+// An orderedMap is a JSON object whose keys are kept in the order in which
+// they were first set. It's marshaled with its keys in that order.
+// -----------------------------------------------------------------------------
+type orderedMap struct {
+	keys   []string               // The object's keys in the order they were first set
+	values map[string]interface{} // The object's values by key
+}
+
+// -----------------------------------------------------------------------------
+// Function     : newOrderedMap()
+// Input        : size - The number of keys that the object is expected to hold
+// Output       : A pointer to a new, empty orderedMap
+// Side Effects : none
+//
+// Abstract :
+// This function creates an empty JSON object.
+// -----------------------------------------------------------------------------
+func newOrderedMap(size int) *orderedMap {
+	return &orderedMap{keys: make([]string, 0, size), values: make(map[string]interface{}, size)}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : orderedMap.set()
+// Input        :
+// key - A string representing the key to set
+// value - The typeless value to set the key to
+//
+// Output       : none
+// Side Effects : The key is set, and added after every other key if it's new
+//
+// Abstract :
+// This method sets the value of a key. A key that's set again keeps its
+// original place.
+// -----------------------------------------------------------------------------
+func (m *orderedMap) set(key string, value interface{}) {
+
+	_, ok := m.values[key]
+
+	if !ok {
+		m.keys = append(m.keys, key)
+	}
+
+	m.values[key] = value
+}
+
+// -----------------------------------------------------------------------------
+// Method       : orderedMap.MarshalJSON()
+// Input        : none
+// Output       :
+// data - The object as compact JSON, with its keys in order
+// err - An error if one of the object's values couldn't be marshaled
+//
+// Side Effects : none
+//
+// Abstract :
+// This method implements the json.Marshaler interface for orderedMap. The
+// encoding/json package indents the result when it's marshaled with
+// json.MarshalIndent.
+// -----------------------------------------------------------------------------
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+
+	for i, k := range m.keys {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		key, err := json.Marshal(k)
+
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(m.values[k])
+
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : unmarshalOrdered()
+// Input        : data - A JSON document
+// Output       :
+// value - A typeless value representing the document, in which every JSON
+// object is an *orderedMap
+// err - An error describing why the document isn't valid JSON
+//
+// Side Effects : none
+//
+// Abstract :
+// This function parses a JSON document in the same way as json.Unmarshal into
+// an interface{}, except that objects keep the order of their keys and
+// numbers are kept as json.Numbers, so that they're written out exactly as
+// they were given. The document is checked with json.Unmarshal first so that
+// invalid JSON is reported with the same errors.
+// -----------------------------------------------------------------------------
+func unmarshalOrdered(data []byte) (interface{}, error) {
+
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decodeOrdered(decoder)
+}
+
+// -----------------------------------------------------------------------------
+// Function     : decodeOrdered()
+// Input        : decoder - A JSON decoder positioned at the start of a value
+// Output       :
+// value - A typeless value representing the next value in the decoder
+// err - An error describing why the value couldn't be read
+//
+// Side Effects : The decoder is advanced past the value
+//
+// Abstract :
+// This function reads a single JSON value token by token, recursing into
+// objects and arrays.
+// -----------------------------------------------------------------------------
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+
+	token, err := decoder.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := newOrderedMap(0)
+
+		for decoder.More() {
+			key, err := decoder.Token()
+
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeOrdered(decoder)
+
+			if err != nil {
+				return nil, err
+			}
+
+			object.set(key.(string), value)
+		}
+
+		_, err = decoder.Token() // Consume the closing brace
+
+		return object, err
+
+	case json.Delim('['):
+		list := []interface{}{}
+
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, value)
+		}
+
+		_, err = decoder.Token() // Consume the closing bracket
+
+		return list, err
+	}
+
+	return token, nil
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,536 @@
+// -----------------------------------------------------------------------------
+// File     : output.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains the writers that a Converter hands each of its output
+// objects to as soon as the object has been generated. A writer decides when
+// and how those objects reach the output, either collecting them until the
+// conversion is complete or writing them out immediately so that the memory
+// used by a conversion doesn't grow with the size of its input.
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Type     : Format
+//
+// Abstract :
+// A Format selects the layout of a Converter's output.
+// -----------------------------------------------------------------------------
+type Format int
+
+const (
+	// FormatJSON writes a single indented JSON object that maps the name of
+	// each collection to a list of the collection's objects
+	FormatJSON Format = iota
+
+	// FormatNDJSON writes each object as a line of compact JSON, also known
+	// as newline delimited JSON or JSON Lines
+	FormatNDJSON
+)
+
+// -----------------------------------------------------------------------------
+// Type     : Output
+//
+// Abstract :
+// An Output combines the objects converted from any number of XML inputs into
+// a single output, as though the inputs had been one XML document. Objects
+// from every input that belong to the same collection are listed together.
+//
+// Example Usage:
+// output, err := converter.NewOutput(os.Stdout)
+// err = output.Convert(firstXMLReader)
+// err = output.Convert(secondXMLReader)
+// err = output.Close()
+// -----------------------------------------------------------------------------
+type Output struct {
+	converter *Converter
+	out       objectWriter
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Converter.NewOutput()
+// Input        : w - A writer that will receive the converted JSON data
+// Output       :
+// output - An Output that writes to w in the Converter's format
+// err - An error if the Converter's format is unknown
+//
+// Side Effects : none
+//
+// Abstract :
+// This method begins an output that XML inputs can be converted into one at a
+// time. The output is only complete once it has been closed.
+// -----------------------------------------------------------------------------
+func (c *Converter) NewOutput(w io.Writer) (*Output, error) {
+
+	// Hand each output object to a writer as soon as it's generated
+	var out objectWriter
+	indent := "  "
+
+	if c.Compact {
+		indent = ""
+	}
+
+	switch {
+	case c.Format == FormatNDJSON:
+		out = &ndjsonWriter{w: w, collectionKey: c.CollectionKey}
+	case c.Format != FormatJSON:
+		return nil, fmt.Errorf("unknown output format %d", c.Format)
+	case c.Stream:
+		out = newStreamWriter(w, indent)
+	default:
+		out = newBufferedWriter(w, indent, c.collections)
+	}
+
+	return &Output{converter: c, out: out}, nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Output.Convert()
+// Input        : r - A reader supplying the XML data to be converted
+// Output       : err - An error describing why the conversion could not be completed
+// Side Effects : Converted objects are added to the output
+//
+// Abstract :
+// This method converts an XML input and adds its objects to the output.
+// -----------------------------------------------------------------------------
+func (o *Output) Convert(r io.Reader) error {
+	return o.converter.convert(r, o.out, nil)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Output.Close()
+// Input        : none
+// Output       : err - An error describing why the output couldn't be written
+// Side Effects : The output is completed
+//
+// Abstract :
+// This method completes the output, writing any objects that are still held
+// in memory. It doesn't close the underlying writer.
+// -----------------------------------------------------------------------------
+func (o *Output) Close() error {
+	return o.out.close()
+}
+
+// -----------------------------------------------------------------------------
+// Type     : objectWriter
+//
+// Abstract :
+// An objectWriter receives the output objects of a conversion in the order in
+// which their XML elements were closed. Once every object has been written,
+// close is called to complete the output.
+// -----------------------------------------------------------------------------
+type objectWriter interface {
+	writeObject(collection string, object interface{}) error
+	close() error
+}
+
+// -----------------------------------------------------------------------------
+// BUFFERED OUTPUT
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : bufferedWriter
+//
+// Abstract :
+// A bufferedWriter holds every output object in memory and writes them as a
+// single JSON object, keyed by collection, once the conversion is complete.
+// Objects from every occurrence of a collection are merged into the same list.
+// Collections are written in the order in which the configuration file lists
+// them. The output is indented with the given indent, or compact if it's empty.
+//
+// Example: Patients -> List of objects
+// -----------------------------------------------------------------------------
+type bufferedWriter struct {
+	w            io.Writer
+	indent       string
+	collections  []string // The order in which collections are written
+	parentKeyMap map[string][]interface{}
+}
+
+func newBufferedWriter(w io.Writer, indent string, collections []string) *bufferedWriter {
+	return &bufferedWriter{w: w, indent: indent, collections: collections, parentKeyMap: make(map[string][]interface{})}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : bufferedWriter.writeObject()
+// Input        :
+// collection - The name of the collection that the object belongs to
+// object - An output object, or nil to record an empty collection
+//
+// Output       : err - Always nil
+// Side Effects : The object is held in memory until the writer is closed
+//
+// Abstract :
+// This method adds an object to the list of objects in its collection. A nil
+// object creates the collection's list without adding to it so that empty
+// collections still appear in the output.
+// -----------------------------------------------------------------------------
+func (b *bufferedWriter) writeObject(collection string, object interface{}) error {
+
+	// If we encounter a new parent key, initialize it with an empty list
+	_, ok := b.parentKeyMap[collection]
+
+	if !ok {
+		b.parentKeyMap[collection] = []interface{}{}
+	}
+
+	if object != nil {
+		b.parentKeyMap[collection] = append(b.parentKeyMap[collection], object)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : bufferedWriter.close()
+// Input        : none
+// Output       : err - An error describing why the output couldn't be written
+// Side Effects : Every collection is written to the output writer
+//
+// Abstract :
+// This method marshals the collections into JSON and writes them to the
+// output writer.
+// -----------------------------------------------------------------------------
+func (b *bufferedWriter) close() error {
+
+	// Only the collections that were found in the input are written
+	output := newOrderedMap(len(b.parentKeyMap))
+
+	for _, collection := range b.collections {
+		objects, ok := b.parentKeyMap[collection]
+
+		if ok {
+			output.set(collection, objects)
+		}
+	}
+
+	// Marshal the output to JSON
+	var jsonData []byte
+	var err error
+
+	if b.indent != "" {
+		jsonData, err = json.MarshalIndent(output, "", b.indent)
+	} else {
+		jsonData, err = json.Marshal(output)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error marshaling the output JSON: %w", err)
+	}
+
+	_, err = b.w.Write(append(jsonData, '\n'))
+
+	return err
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// STREAMED OUTPUT
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : streamWriter
+//
+// Abstract :
+// A streamWriter writes each output object as soon as it's received, so only
+// one object needs to be held in memory at a time. The output has the same
+// shape as a bufferedWriter's, but collections appear in the order in which
+// they're found in the input rather than in configuration file order.
+//
+// Because a collection's list is closed as soon as another collection begins,
+// each collection has to appear in one unbroken run in the input. Objects
+// from two occurrences of the same collection with nothing in between are
+// merged into one list.
+// -----------------------------------------------------------------------------
+type streamWriter struct {
+	w          io.Writer
+	indent     string          // The indent of each level of the output, or empty for compact output
+	collection string          // The collection whose list is currently open
+	written    map[string]bool // The collections whose lists have been closed
+	objects    int             // The number of objects in the open list
+}
+
+func newStreamWriter(w io.Writer, indent string) *streamWriter {
+	return &streamWriter{w: w, indent: indent, written: make(map[string]bool)}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : streamWriter.writeObject()
+// Input        :
+// collection - The name of the collection that the object belongs to
+// object - An output object, or nil to record an empty collection
+//
+// Output       :
+// err - An error describing why the object couldn't be written, including
+// when its collection has already been closed
+//
+// Side Effects : The object is written to the output writer
+//
+// Abstract :
+// This method writes an object into its collection's list, opening the list
+// and closing the previous collection's list if needed. Everything is written
+// with a single call to the output writer so that each object reaches the
+// output as soon as it has been converted.
+// -----------------------------------------------------------------------------
+func (s *streamWriter) writeObject(collection string, object interface{}) error {
+
+	var buffer bytes.Buffer
+
+	if collection != s.collection {
+
+		if s.written[collection] {
+			return fmt.Errorf("collection %s appears more than once in the input and can't be streamed", collection)
+		}
+
+		// Close the list of the previous collection, or open the output
+		separator := "{"
+
+		if s.collection != "" {
+			s.closeList(&buffer)
+			s.written[s.collection] = true
+			separator = ","
+		}
+
+		key, err := json.Marshal(collection)
+
+		if err != nil {
+			return fmt.Errorf("error marshaling the output JSON: %w", err)
+		}
+
+		buffer.WriteString(separator)
+		s.newline(&buffer, 1)
+		buffer.Write(key)
+		buffer.WriteString(":")
+
+		if s.indent != "" {
+			buffer.WriteString(" ")
+		}
+
+		buffer.WriteString("[")
+		s.collection = collection
+		s.objects = 0
+	}
+
+	if object != nil {
+		err := s.appendObject(&buffer, object)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := s.w.Write(buffer.Bytes())
+
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// Method       : streamWriter.appendObject()
+// Input        :
+// buffer - The buffer that the object is added to
+// object - An output object
+//
+// Output       : err - An error describing why the object couldn't be marshaled
+// Side Effects : The object is added to the buffer
+//
+// Abstract :
+// This method marshals an object into JSON, following the previous object in
+// the open list with a comma.
+// -----------------------------------------------------------------------------
+func (s *streamWriter) appendObject(buffer *bytes.Buffer, object interface{}) error {
+
+	var jsonData []byte
+	var err error
+
+	if s.indent != "" {
+		jsonData, err = json.MarshalIndent(object, strings.Repeat(s.indent, 2), s.indent)
+	} else {
+		jsonData, err = json.Marshal(object)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error marshaling the output JSON: %w", err)
+	}
+
+	if s.objects > 0 {
+		buffer.WriteString(",")
+	}
+
+	s.newline(buffer, 2)
+	buffer.Write(jsonData)
+	s.objects++
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : streamWriter.close()
+// Input        : none
+// Output       : err - An error describing why the output couldn't be written
+// Side Effects : The output is completed
+//
+// Abstract :
+// This method closes the open collection's list along with the output object.
+// -----------------------------------------------------------------------------
+func (s *streamWriter) close() error {
+
+	var buffer bytes.Buffer
+
+	if s.collection == "" {
+		buffer.WriteString("{")
+	} else {
+		s.closeList(&buffer)
+		s.newline(&buffer, 0)
+	}
+
+	buffer.WriteString("}\n")
+	_, err := s.w.Write(buffer.Bytes())
+
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// Method       : streamWriter.closeList()
+// Input        : buffer - The buffer that the end of the list is added to
+// Output       : none
+// Side Effects : The end of the open collection's list is added to the buffer
+//
+// Abstract :
+// This method closes the open collection's list, matching the layout that
+// json.MarshalIndent gives empty and non-empty lists.
+// -----------------------------------------------------------------------------
+func (s *streamWriter) closeList(buffer *bytes.Buffer) {
+
+	if s.objects > 0 {
+		s.newline(buffer, 1)
+	}
+
+	buffer.WriteString("]")
+}
+
+// -----------------------------------------------------------------------------
+// Method       : streamWriter.newline()
+// Input        :
+// buffer - The buffer that the line break is added to
+// depth - The number of levels that the next line is nested by
+//
+// Output       : none
+// Side Effects : A line break and indentation are added to the buffer
+//
+// Abstract :
+// This method begins a new, indented line of output. Compact output is
+// written on a single line, so nothing is added to it.
+// -----------------------------------------------------------------------------
+func (s *streamWriter) newline(buffer *bytes.Buffer, depth int) {
+
+	if s.indent != "" {
+		buffer.WriteString("\n" + strings.Repeat(s.indent, depth))
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// LINE DELIMITED OUTPUT
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : ndjsonWriter
+//
+// Abstract :
+// An ndjsonWriter writes each output object as soon as it's received as a
+// single line of compact JSON. Lines don't say which collection their object
+// belongs to unless collectionKey is set, in which case each object is given
+// an extra field of that name holding the name of its collection.
+//
+// Example: With collectionKey set to 'collection', a patient is written as
+// {"collection":"Patients","name":"John Doe"}
+// -----------------------------------------------------------------------------
+type ndjsonWriter struct {
+	w             io.Writer
+	collectionKey string // The field that names each object's collection, if any
+}
+
+// -----------------------------------------------------------------------------
+// Method       : ndjsonWriter.writeObject()
+// Input        :
+// collection - The name of the collection that the object belongs to
+// object - An output object, or nil to record an empty collection
+//
+// Output       :
+// err - An error describing why the object couldn't be written, including
+// when the object can't be given a collection field
+//
+// Side Effects : The object is written to the output writer as one line
+//
+// Abstract :
+// This method writes an object as a line of JSON, adding its collection's
+// name to it first if the writer has a collection key. Empty collections
+// don't produce any lines.
+// -----------------------------------------------------------------------------
+func (n *ndjsonWriter) writeObject(collection string, object interface{}) error {
+
+	if object == nil {
+		return nil
+	}
+
+	if n.collectionKey != "" {
+		fields, ok := object.(*orderedMap)
+
+		if !ok {
+			return fmt.Errorf("objects in collection %s aren't JSON objects and can't be tagged with their collection", collection)
+		}
+
+		_, ok = fields.values[n.collectionKey]
+
+		if ok {
+			return fmt.Errorf("objects in collection %s already have a field named %s", collection, n.collectionKey)
+		}
+
+		// Copy the object rather than changing the one we were given, putting
+		// the collection first
+		tagged := newOrderedMap(len(fields.keys) + 1)
+		tagged.set(n.collectionKey, collection)
+
+		for _, k := range fields.keys {
+			tagged.set(k, fields.values[k])
+		}
+
+		object = tagged
+	}
+
+	jsonData, err := json.Marshal(object)
+
+	if err != nil {
+		return fmt.Errorf("error marshaling the output JSON: %w", err)
+	}
+
+	_, err = n.w.Write(append(jsonData, '\n'))
+
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// Method       : ndjsonWriter.close()
+// Input        : none
+// Output       : err - Always nil
+// Side Effects : none
+//
+// Abstract :
+// This method does nothing, as each line is complete once it's been written.
+// -----------------------------------------------------------------------------
+func (n *ndjsonWriter) close() error {
+	return nil
+}
+
+// -----------------------------------------------------------------------------
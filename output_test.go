@@ -0,0 +1,424 @@
+package gopherhole
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConvertStream(t *testing.T) {
+
+	config := `{
+		"Patients": [{"id": "<Patients.Patient.ID>", "name": "<Patients.Patient.Name>"}],
+		"Doctors": [{"name": "<Doctors.Doctor.Name>"}],
+		"Nurses": [{"name": "<Nurses.Nurse.Name>"}]
+	}`
+
+	var tests = []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			"single collection",
+			`<Patients><Patient ID="1"><Name>John</Name></Patient><Patient ID="2"><Name>Jane</Name></Patient></Patients>`,
+			"{\n  \"Patients\": [\n    {\n      \"id\": 1,\n      \"name\": \"John\"\n    },\n    {\n      \"id\": 2,\n      \"name\": \"Jane\"\n    }\n  ]\n}\n",
+			false,
+		},
+		{
+			"document order",
+			`<Patients><Patient ID="1"><Name>John</Name></Patient></Patients><Doctors><Doctor><Name>Ada</Name></Doctor></Doctors>`,
+			"{\n  \"Patients\": [\n    {\n      \"id\": 1,\n      \"name\": \"John\"\n    }\n  ],\n  \"Doctors\": [\n    {\n      \"name\": \"Ada\"\n    }\n  ]\n}\n",
+			false,
+		},
+		{
+			"empty collection",
+			`<Nurses></Nurses><Doctors><Doctor><Name>Ada</Name></Doctor></Doctors>`,
+			"{\n  \"Nurses\": [],\n  \"Doctors\": [\n    {\n      \"name\": \"Ada\"\n    }\n  ]\n}\n",
+			false,
+		},
+		{
+			"no collections",
+			``,
+			"{}\n",
+			false,
+		},
+		{
+			"adjacent occurrences",
+			`<Doctors><Doctor><Name>Ada</Name></Doctor></Doctors><Doctors><Doctor><Name>Alan</Name></Doctor></Doctors>`,
+			"{\n  \"Doctors\": [\n    {\n      \"name\": \"Ada\"\n    },\n    {\n      \"name\": \"Alan\"\n    }\n  ]\n}\n",
+			false,
+		},
+		{
+			"separated occurrences",
+			`<Doctors></Doctors><Nurses></Nurses><Doctors></Doctors>`,
+			"",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converter, err := NewConverter(strings.NewReader(config))
+
+			if err != nil {
+				t.Fatalf("Got error %v creating the converter", err)
+			}
+
+			converter.Stream = true
+
+			var output bytes.Buffer
+			err = converter.Convert(strings.NewReader(test.input), &output)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Got %s, wanted an error", output.String())
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %v converting the input", err)
+			}
+
+			if output.String() != test.want {
+				t.Errorf("Got %s, wanted %s", output.String(), test.want)
+			}
+
+			// Streaming shouldn't change the output of a single collection
+			if strings.Count(test.want, "[") != 1 {
+				return
+			}
+
+			converter.Stream = false
+			var buffered bytes.Buffer
+			err = converter.Convert(strings.NewReader(test.input), &buffered)
+
+			if err != nil {
+				t.Fatalf("Got error %v converting the input without streaming", err)
+			}
+
+			if buffered.String() != output.String() {
+				t.Errorf("Got %s streaming and %s without streaming", output.String(), buffered.String())
+			}
+		})
+	}
+}
+
+func TestConvertStreamWritesEarly(t *testing.T) {
+
+	converter, err := NewConverter(strings.NewReader(`{"Patients": [{"name": "<Patients.Patient.Name>"}]}`))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	converter.Stream = true
+
+	// Feed the input through a pipe so that the conversion has to wait for
+	// each piece of it, and check the output before the input is finished
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- converter.Convert(inputReader, outputWriter)
+		outputWriter.Close()
+	}()
+
+	go func() {
+		io.WriteString(inputWriter, `<Patients><Patient><Name>John</Name></Patient>`)
+		io.WriteString(inputWriter, `<Patient><Name>Jane</Name></Patient>`)
+	}()
+
+	// The first object should be written while the input is still open
+	buffer := make([]byte, 1024)
+	var got strings.Builder
+
+	for !strings.Contains(got.String(), "John") {
+		n, err := outputReader.Read(buffer)
+
+		if err != nil {
+			t.Fatalf("Got error %v reading the output", err)
+		}
+
+		got.Write(buffer[:n])
+	}
+
+	go func() {
+		io.WriteString(inputWriter, `</Patients>`)
+		inputWriter.Close()
+	}()
+
+	rest, _ := io.ReadAll(outputReader)
+	got.Write(rest)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Got error %v converting the input", err)
+	}
+
+	want := `{"Patients":[{"name":"John"},{"name":"Jane"}]}`
+
+	if compactJSON(t, got.String()) != want {
+		t.Errorf("Got %s, wanted %s", got.String(), want)
+	}
+}
+
+func TestConvertNDJSON(t *testing.T) {
+
+	input := `<Patients><Patient ID="1"><Name>John</Name></Patient><Patient ID="2"><Name>Jane</Name></Patient></Patients>
+	<Doctors></Doctors>
+	<Nurses><Nurse><Name>Florence</Name></Nurse></Nurses>`
+
+	var tests = []struct {
+		name          string
+		config        string
+		collectionKey string
+		want          string
+		wantErr       bool
+	}{
+		{
+			"untagged",
+			`{"Patients": [{"id": "<Patients.Patient.ID>", "name": "<Patients.Patient.Name>"}], "Doctors": [{}], "Nurses": [{"name": "<Nurses.Nurse.Name>"}]}`,
+			"",
+			"{\"id\":1,\"name\":\"John\"}\n{\"id\":2,\"name\":\"Jane\"}\n{\"name\":\"Florence\"}\n",
+			false,
+		},
+		{
+			"tagged",
+			`{"Patients": [{"id": "<Patients.Patient.ID>", "name": "<Patients.Patient.Name>"}], "Nurses": [{"name": "<Nurses.Nurse.Name>"}]}`,
+			"collection",
+			"{\"collection\":\"Patients\",\"id\":1,\"name\":\"John\"}\n{\"collection\":\"Patients\",\"id\":2,\"name\":\"Jane\"}\n{\"collection\":\"Nurses\",\"name\":\"Florence\"}\n",
+			false,
+		},
+		{
+			"tag collides with a field",
+			`{"Patients": [{"id": "<Patients.Patient.ID>"}]}`,
+			"id",
+			"",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converter, err := NewConverter(strings.NewReader(test.config))
+
+			if err != nil {
+				t.Fatalf("Got error %v creating the converter", err)
+			}
+
+			converter.Format = FormatNDJSON
+			converter.CollectionKey = test.collectionKey
+
+			var output bytes.Buffer
+			err = converter.Convert(strings.NewReader(input), &output)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Got %s, wanted an error", output.String())
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %v converting the input", err)
+			}
+
+			if output.String() != test.want {
+				t.Errorf("Got %s, wanted %s", output.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestConvertUnknownFormat(t *testing.T) {
+
+	converter, err := NewConverter(strings.NewReader(`{"Patients": [{"id": "<Patients.Patient.ID>"}]}`))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	converter.Format = Format(42)
+	err = converter.Convert(strings.NewReader(`<Patients></Patients>`), io.Discard)
+
+	if err == nil {
+		t.Errorf("Got no error, wanted an error for an unknown output format")
+	}
+}
+
+func TestConvertCompact(t *testing.T) {
+
+	config := `{"Patients": [{"id": "<Patients.Patient.ID>", "name": "<Patients.Patient.Name>"}], "Doctors": [{}]}`
+
+	var tests = []struct {
+		name   string
+		input  string
+		stream bool
+		want   string
+	}{
+		{"buffered", `<Patients><Patient ID="1"><Name>John</Name></Patient><Patient ID="2"><Name>Jane</Name></Patient></Patients>`, false, `{"Patients":[{"id":1,"name":"John"},{"id":2,"name":"Jane"}]}` + "\n"},
+		{"streamed", `<Patients><Patient ID="1"><Name>John</Name></Patient><Patient ID="2"><Name>Jane</Name></Patient></Patients>`, true, `{"Patients":[{"id":1,"name":"John"},{"id":2,"name":"Jane"}]}` + "\n"},
+		{"streamed empty collection", `<Doctors></Doctors><Patients><Patient ID="1"><Name>John</Name></Patient></Patients>`, true, `{"Doctors":[],"Patients":[{"id":1,"name":"John"}]}` + "\n"},
+		{"streamed nothing", ``, true, "{}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converter, err := NewConverter(strings.NewReader(config))
+
+			if err != nil {
+				t.Fatalf("Got error %v creating the converter", err)
+			}
+
+			converter.Compact = true
+			converter.Stream = test.stream
+
+			var output bytes.Buffer
+			err = converter.Convert(strings.NewReader(test.input), &output)
+
+			if err != nil {
+				t.Fatalf("Got error %v converting the input", err)
+			}
+
+			if output.String() != test.want {
+				t.Errorf("Got %s, wanted %s", output.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestOutputMergesInputs(t *testing.T) {
+
+	config := `{"Patients": [{"id": "<Patients.Patient.ID>"}], "Doctors": [{"id": "<Doctors.Doctor.ID>"}]}`
+	inputs := []string{
+		`<Patients><Patient ID="1"></Patient></Patients>`,
+		`<Patients><Patient ID="2"></Patient></Patients><Doctors><Doctor ID="3"></Doctor></Doctors>`,
+	}
+
+	for _, stream := range []bool{false, true} {
+		converter, err := NewConverter(strings.NewReader(config))
+
+		if err != nil {
+			t.Fatalf("Got error %v creating the converter", err)
+		}
+
+		converter.Stream = stream
+
+		var buffer bytes.Buffer
+		output, err := converter.NewOutput(&buffer)
+
+		if err != nil {
+			t.Fatalf("Got error %v creating the output", err)
+		}
+
+		for _, input := range inputs {
+			err = output.Convert(strings.NewReader(input))
+
+			if err != nil {
+				t.Fatalf("Got error %v converting %s", err, input)
+			}
+		}
+
+		err = output.Close()
+
+		if err != nil {
+			t.Fatalf("Got error %v closing the output", err)
+		}
+
+		got := compactJSON(t, buffer.String())
+		want := `{"Patients":[{"id":1},{"id":2}],"Doctors":[{"id":3}]}`
+
+		if got != want {
+			t.Errorf("Got %s, wanted %s when streaming is %t", got, want, stream)
+		}
+	}
+}
+
+func TestConvertConfigOrder(t *testing.T) {
+
+	config := `{
+		"Wards": [{"name": "<Wards.Ward.Name>"}],
+		"Patients": [{"zip": "<Patients.Patient.Zip>", "id": "<Patients.Patient.ID>", "visit": {"when": "<Patients.Patient.Date>", "at": "<Patients.Patient.Ward>"}}]
+	}`
+
+	input := `<Patients><Patient ID="1"><Zip>12345</Zip><Date>2025-01-01</Date><Ward>A</Ward></Patient></Patients><Wards><Ward><Name>A</Name></Ward></Wards>`
+
+	var tests = []struct {
+		name          string
+		format        Format
+		stream        bool
+		collectionKey string
+		want          string
+	}{
+		{"buffered", FormatJSON, false, "", `{
+  "Wards": [
+    {
+      "name": "A"
+    }
+  ],
+  "Patients": [
+    {
+      "zip": 12345,
+      "id": 1,
+      "visit": {
+        "when": "2025-01-01",
+        "at": "A"
+      }
+    }
+  ]
+}
+`},
+		{"streamed", FormatJSON, true, "", `{
+  "Patients": [
+    {
+      "zip": 12345,
+      "id": 1,
+      "visit": {
+        "when": "2025-01-01",
+        "at": "A"
+      }
+    }
+  ],
+  "Wards": [
+    {
+      "name": "A"
+    }
+  ]
+}
+`},
+		{"ndjson", FormatNDJSON, false, "collection", `{"collection":"Patients","zip":12345,"id":1,"visit":{"when":"2025-01-01","at":"A"}}
+{"collection":"Wards","name":"A"}
+`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converter, err := NewConverter(strings.NewReader(config))
+
+			if err != nil {
+				t.Fatalf("Got error %v creating the converter", err)
+			}
+
+			converter.Format = test.format
+			converter.Stream = test.stream
+			converter.CollectionKey = test.collectionKey
+
+			var output bytes.Buffer
+			err = converter.Convert(strings.NewReader(input), &output)
+
+			if err != nil {
+				t.Fatalf("Got error %v converting the input", err)
+			}
+
+			if output.String() != test.want {
+				t.Errorf("Got %s, wanted %s", output.String(), test.want)
+			}
+		})
+	}
+}
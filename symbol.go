@@ -0,0 +1,701 @@
+// -----------------------------------------------------------------------------
+// File     : symbol.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains the grammar of the find and replace symbols that are
+// embedded within a configuration file's object definitions, along with the
+// parser that breaks a symbol into its name and its modifiers.
+//
+// Grammar:
+// symbol         = '<' name { whitespace modifier } '>'
+// name           = segment { '.' segment } [ '.@' attribute ]
+// segment        = [ prefix ':' ] element name
+// attribute      = [ prefix ':' ] attribute name
+// modifier       = key [ '=' value ]
+// value          = quoted string | bare word
+// transform      = 'transform=' transformation { '|' transformation }
+// transformation = name [ '(' [ argument { ',' argument } ] ')' ]
+// argument       = [ key '=' ] ( quoted string | bare word )
+//
+// Example: <Patients.Patient.LastName transform=trim|upper>
+// Example: <Patients.Patient.Visit transform=dateFormat(from="01/02/2006", to="2006-01-02")>
+// Example: <Patients.Patient.MiddleName default="unknown">
+// Example: <Patients.Patient.ID required>
+// Example: <Patients.Patient.Notes whitespace=collapse>
+// Example: <p:Patients.p:Patient.ext:MRN>
+// Example: <Patients.Patient.Phone.@type>
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Package level constants
+const segmentExpression = `([a-zA-Z_][a-zA-Z0-9_\-]*:)?[a-zA-Z_][a-zA-Z0-9_\-]*`   // An XML element name with an optional namespace prefix
+const FindAndReplaceExpression = `<[a-zA-Z_][^<>"]*(?:"(?:[^"\\]|\\.)*"[^<>"]*)*>` // Regex for use in replacing the config file's find and replace symbols
+
+// Package level variables
+var findAndReplaceRegex = regexp.MustCompile(FindAndReplaceExpression)                                                               // Compiled find and replace symbol regex
+var elementKeyRegex = regexp.MustCompile(`^` + segmentExpression + `(\.` + segmentExpression + `)*$`)                                // Dotted XML element names
+var symbolNameRegex = regexp.MustCompile(`^` + segmentExpression + `(\.` + segmentExpression + `)*(\.@` + segmentExpression + `)?$`) // Dotted XML element names, optionally ending in an attribute
+var prefixRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_\-]*$`)                                                                   // Namespace prefixes
+var types = map[string]bool{"string": true, "int": true, "float": true, "bool": true, "nullIfEmpty": true}
+var whitespacePolicies = map[string]Whitespace{"preserve": WhitespacePreserve, "trim": WhitespaceTrim, "collapse": WhitespaceCollapse}
+
+// -----------------------------------------------------------------------------
+// TYPES
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : Symbol
+//
+// Abstract :
+// A Symbol is a parsed find and replace symbol. Its name is the xmlKey whose
+// value replaces the symbol, and its modifiers describe how that value should
+// be changed before it does.
+//
+// Example: For <Patients.Patient.DateOfBirth transform=yearsElapsed>, Name is
+// 'Patients.Patient.DateOfBirth' and Modifiers contains the transform modifier
+// -----------------------------------------------------------------------------
+type Symbol struct {
+	Name      string    // The xmlKey named by the symbol
+	Modifiers Modifiers // The symbol's modifiers, in the order they were written
+}
+
+// -----------------------------------------------------------------------------
+// Type     : Modifier
+//
+// Abstract :
+// A Modifier is a single key and value pair written after the name of a find
+// and replace symbol, e.g. transform=yearsElapsed. A modifier written without a
+// value, e.g. repeat, is a flag whose value is 'true'. Quoted values are stored
+// without their quotes. For transform modifiers, Transformations holds the
+// parsed pipeline.
+// -----------------------------------------------------------------------------
+type Modifier struct {
+	Key             string           // The modifier's name, e.g. transform
+	Value           string           // The modifier's value, e.g. yearsElapsed
+	Transformations []Transformation // The pipeline of a transform modifier
+}
+
+// -----------------------------------------------------------------------------
+// Type     : Modifiers
+//
+// Abstract :
+// Modifiers is the list of modifiers written within a find and replace symbol,
+// kept in the order in which they were written. Only the transform modifier
+// may appear more than once.
+// -----------------------------------------------------------------------------
+type Modifiers []Modifier
+
+// -----------------------------------------------------------------------------
+// Type     : Transformation
+//
+// Abstract :
+// A Transformation is one step of a transform modifier's pipeline, naming a
+// registered transformation along with the arguments that it was given.
+//
+// Example: For transform=substring(0, 3), Name is 'substring' and Args holds
+// the positional arguments '0' and '3'
+// -----------------------------------------------------------------------------
+type Transformation struct {
+	Name string // The name of a registered transformation
+	Args Args   // The arguments written in parentheses after the name
+}
+
+// -----------------------------------------------------------------------------
+// Type     : SymbolError
+//
+// Abstract :
+// A SymbolError describes why a find and replace symbol couldn't be parsed,
+// along with the position within the symbol at which the problem was found.
+// -----------------------------------------------------------------------------
+type SymbolError struct {
+	Symbol   string // The symbol that couldn't be parsed
+	Position int    // The byte offset within the symbol of the problem
+	Message  string // A description of the problem
+}
+
+// -----------------------------------------------------------------------------
+// Method       : SymbolError.Error()
+// Input        : none
+// Output       : A string describing the error
+// Side Effects : none
+//
+// Abstract :
+// This method implements the error interface for SymbolError.
+// -----------------------------------------------------------------------------
+func (e *SymbolError) Error() string {
+	return fmt.Sprintf("invalid find and replace symbol %s: %s at position %d", e.Symbol, e.Message, e.Position)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Modifiers.Get()
+// Input        : key - A string representing the name of a modifier
+// Output       :
+// value - A string representing the value of the last modifier with the name
+// ok - A boolean value representing whether a modifier with the name exists
+//
+// Side Effects : none
+//
+// Abstract :
+// This method finds the value of a modifier by name. If the modifier appears
+// more than once, the value written last is used.
+// -----------------------------------------------------------------------------
+func (m Modifiers) Get(key string) (string, bool) {
+
+	for i := len(m) - 1; i >= 0; i-- {
+		if m[i].Key == key {
+			return m[i].Value, true
+		}
+	}
+
+	return "", false
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Modifiers.Flag()
+// Input        : key - A string representing the name of a modifier
+// Output       :
+// A boolean value representing whether the modifier is present and true
+//
+// Side Effects : none
+//
+// Abstract :
+// This method determines whether a flag such as repeat has been turned on.
+// Both <Patients.Patient.Phone repeat> and <Patients.Patient.Phone repeat=true>
+// turn the repeat flag on.
+// -----------------------------------------------------------------------------
+func (m Modifiers) Flag(key string) bool {
+
+	value, ok := m.Get(key)
+
+	if !ok {
+		return false
+	}
+
+	flag, _ := strconv.ParseBool(value)
+
+	return flag
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Modifiers.Transformations()
+// Input        : none
+// Output       :
+// A list of the transformations to be applied, in the order in which they
+// should be applied
+//
+// Side Effects : none
+//
+// Abstract :
+// This method builds the pipeline of transformations named by a symbol's
+// transform modifiers. Transformations may be chained within one modifier by
+// separating them with '|', and the transform modifier may be repeated. In
+// either case, transformations are applied from left to right.
+//
+// Example: Both <Patients.Patient.LastName transform=trim|upper> and
+// <Patients.Patient.LastName transform=trim transform=upper> produce the
+// pipeline [trim, upper]
+// -----------------------------------------------------------------------------
+func (m Modifiers) Transformations() []Transformation {
+
+	transformations := []Transformation{}
+
+	for _, modifier := range m {
+		transformations = append(transformations, modifier.Transformations...)
+	}
+
+	return transformations
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// PARSING
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : ParseFindAndReplaceSymbol
+//
+// Input :
+// s - A string representing a find and replace symbol in the config.json file
+// Example: <Patients.Patient.DateOfBirth transform=yearsElapsed>
+//
+// Output :
+// symbol - The symbol's name and its list of modifiers, in order
+// err - A *SymbolError describing why the symbol couldn't be parsed
+//
+// Side Effects : none
+//
+// Abstract :
+// This function takes in a find and replace symbol from the config.json file
+// and breaks it into a name and a list of modifiers, checking that each
+// modifier is one that gopherhole understands.
+//
+// Example: For <Patients.Patient.DateOfBirth transform=yearsElapsed>, name
+// is 'Patients.Patient.DateOfBirth'
+//
+// Example: For <Patients.Patient.DateOfBirth transform=yearsElapsed>, modifiers
+// contains the key 'transform' with the value 'yearsElapsed'
+//
+// Example: For <Patients.Patient.Phone repeat>, modifiers contains the key
+// 'repeat' with the value 'true'
+// Note: In the case where there is no modifier in a find and replace symbol,
+// modifiers is a nil value
+// -----------------------------------------------------------------------------
+func ParseFindAndReplaceSymbol(s string) (Symbol, error) {
+
+	p := &symbolParser{symbol: s}
+
+	if !strings.HasPrefix(s, "<") {
+		return Symbol{}, p.newError("expected '<'")
+	}
+
+	if len(s) < 2 || !strings.HasSuffix(s, ">") {
+		return Symbol{}, &SymbolError{Symbol: s, Position: len(s), Message: "expected '>'"}
+	}
+
+	p.input = s[:len(s)-1]
+	p.position = 1
+
+	// Read the symbol's name
+	p.skipWhitespace()
+	start := p.position
+	p.readWhile(func(r rune) bool { return !unicode.IsSpace(r) })
+	name := p.input[start:p.position]
+
+	if name == "" {
+		return Symbol{}, p.newError("expected a name")
+	}
+
+	if !symbolNameRegex.MatchString(name) {
+		return Symbol{}, &SymbolError{Symbol: s, Position: start, Message: fmt.Sprintf("invalid name '%s'", name)}
+	}
+
+	symbol := Symbol{Name: name}
+
+	// Read each of the symbol's modifiers
+	for {
+		p.skipWhitespace()
+
+		if p.done() {
+			break
+		}
+
+		modifier, err := p.parseModifier()
+
+		if err != nil {
+			return Symbol{}, err
+		}
+
+		// Only the transform modifier may be repeated
+		_, ok := symbol.Modifiers.Get(modifier.Key)
+
+		if ok && modifier.Key != "transform" {
+			return Symbol{}, p.newError(fmt.Sprintf("modifier '%s' given more than once", modifier.Key))
+		}
+
+		symbol.Modifiers = append(symbol.Modifiers, modifier)
+	}
+
+	return symbol, nil
+}
+
+// -----------------------------------------------------------------------------
+// Type     : symbolParser
+//
+// Abstract :
+// A symbolParser keeps track of its position within a find and replace symbol
+// as the symbol is broken into its parts. The parser's input is the symbol
+// without its closing '>'.
+// -----------------------------------------------------------------------------
+type symbolParser struct {
+	symbol   string // The complete symbol, used in error messages
+	input    string // The symbol without its closing '>'
+	position int    // The byte offset of the next character to be read
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.parseModifier()
+// Input        : none
+// Output       :
+// modifier - The modifier found at the parser's position
+// err - A *SymbolError describing why the modifier couldn't be parsed
+//
+// Side Effects : The parser's position is advanced past the modifier
+//
+// Abstract :
+// This method reads a single modifier and checks that it is one that
+// gopherhole understands: transform, type, repeat, required, default or
+// whitespace.
+// -----------------------------------------------------------------------------
+func (p *symbolParser) parseModifier() (Modifier, error) {
+
+	start := p.position
+	key := p.readIdentifier()
+
+	if key == "" {
+		return Modifier{}, p.newError(fmt.Sprintf("unexpected character '%c'", p.peek()))
+	}
+
+	modifier := Modifier{Key: key, Value: "true"}
+
+	if p.peek() == '=' {
+		p.position++
+
+		// The value of a transform modifier is a pipeline of transformations
+		if key == "transform" {
+			valueStart := p.position
+			transformations, err := p.parsePipeline()
+
+			if err != nil {
+				return Modifier{}, err
+			}
+
+			modifier.Value = p.input[valueStart:p.position]
+			modifier.Transformations = transformations
+		} else {
+			value, err := p.parseValue()
+
+			if err != nil {
+				return Modifier{}, err
+			}
+
+			modifier.Value = value
+		}
+	}
+
+	// Modifiers are separated by whitespace
+	if !p.done() && !unicode.IsSpace(p.peek()) {
+		return Modifier{}, p.newError(fmt.Sprintf("unexpected character '%c'", p.peek()))
+	}
+
+	// Check that the modifier is one that we understand
+	switch key {
+	case "transform":
+		if modifier.Transformations == nil {
+			return Modifier{}, &SymbolError{Symbol: p.symbol, Position: start, Message: "the transform modifier requires a transformation"}
+		}
+	case "type":
+		if !types[modifier.Value] {
+			return Modifier{}, &SymbolError{Symbol: p.symbol, Position: start, Message: fmt.Sprintf("unknown type '%s'", modifier.Value)}
+		}
+	case "whitespace":
+		_, ok := whitespacePolicies[modifier.Value]
+
+		if !ok {
+			return Modifier{}, &SymbolError{Symbol: p.symbol, Position: start, Message: fmt.Sprintf("unknown whitespace policy '%s', expected preserve, trim or collapse", modifier.Value)}
+		}
+	case "repeat", "required":
+		_, err := strconv.ParseBool(modifier.Value)
+
+		if err != nil {
+			return Modifier{}, &SymbolError{Symbol: p.symbol, Position: start, Message: fmt.Sprintf("invalid %s value '%s'", key, modifier.Value)}
+		}
+	case "default":
+	default:
+		return Modifier{}, &SymbolError{Symbol: p.symbol, Position: start, Message: fmt.Sprintf("unknown modifier '%s'", key)}
+	}
+
+	return modifier, nil
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.parsePipeline()
+// Input        : none
+// Output       :
+// transformations - The transformations found at the parser's position
+// err - A *SymbolError describing why the pipeline couldn't be parsed
+//
+// Side Effects : The parser's position is advanced past the pipeline
+//
+// Abstract :
+// This method reads a pipeline of transformations separated by '|', each of
+// which may be followed by a list of arguments in parentheses.
+//
+// Example: trim|dateFormat(from="01/02/2006", to="2006-01-02")
+// -----------------------------------------------------------------------------
+func (p *symbolParser) parsePipeline() ([]Transformation, error) {
+
+	transformations := []Transformation{}
+
+	for {
+		name := p.readIdentifier()
+
+		if name == "" {
+			return nil, p.newError("expected the name of a transformation")
+		}
+
+		transformation := Transformation{Name: name}
+
+		if p.peek() == '(' {
+			p.position++
+			args, err := p.parseArguments()
+
+			if err != nil {
+				return nil, err
+			}
+
+			transformation.Args = args
+		}
+
+		transformations = append(transformations, transformation)
+
+		if p.peek() != '|' {
+			return transformations, nil
+		}
+
+		p.position++
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.parseArguments()
+// Input        : none
+// Output       :
+// args - The arguments found at the parser's position
+// err - A *SymbolError describing why the arguments couldn't be parsed
+//
+// Side Effects : The parser's position is advanced past the closing ')'
+//
+// Abstract :
+// This method reads a comma separated list of arguments up to and including
+// the closing ')'. Arguments written as name=value are named, and all other
+// arguments are positional.
+//
+// Example: from="01/02/2006", to="2006-01-02")
+// -----------------------------------------------------------------------------
+func (p *symbolParser) parseArguments() (Args, error) {
+
+	args := Args{}
+
+	p.skipWhitespace()
+
+	if p.peek() == ')' {
+		p.position++
+		return args, nil
+	}
+
+	for {
+		p.skipWhitespace()
+
+		// Look ahead for a name followed by '='
+		start := p.position
+		name := p.readIdentifier()
+		p.skipWhitespace()
+
+		if name == "" || p.peek() != '=' {
+			name = ""
+			p.position = start
+		} else {
+			p.position++
+			p.skipWhitespace()
+		}
+
+		value, err := p.parseValue()
+
+		if err != nil {
+			return Args{}, err
+		}
+
+		if name == "" {
+			args.Positional = append(args.Positional, value)
+		} else {
+			_, ok := args.Named[name]
+
+			if ok {
+				return Args{}, p.newError(fmt.Sprintf("argument '%s' given more than once", name))
+			}
+
+			if args.Named == nil {
+				args.Named = map[string]string{}
+			}
+
+			args.Named[name] = value
+		}
+
+		p.skipWhitespace()
+
+		switch p.peek() {
+		case ',':
+			p.position++
+		case ')':
+			p.position++
+			return args, nil
+		case 0:
+			return Args{}, p.newError("expected ')'")
+		default:
+			return Args{}, p.newError(fmt.Sprintf("unexpected character '%c'", p.peek()))
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.parseValue()
+// Input        : none
+// Output       :
+// value - A string representing the value found at the parser's position,
+// without its quotes if it was quoted
+// err - A *SymbolError describing why the value couldn't be parsed
+//
+// Side Effects : The parser's position is advanced past the value
+//
+// Abstract :
+// This method reads either a quoted string or a bare word. Quoted strings may
+// contain any character, with '\"' and '\\' used to write a quote and a
+// backslash. Bare words end at whitespace or at any of the characters that
+// separate the parts of a symbol.
+// -----------------------------------------------------------------------------
+func (p *symbolParser) parseValue() (string, error) {
+
+	if p.peek() != '"' {
+		start := p.position
+		p.readWhile(func(r rune) bool { return !unicode.IsSpace(r) && !strings.ContainsRune(`"=|(),`, r) })
+
+		if p.position == start {
+			return "", p.newError("expected a value")
+		}
+
+		return p.input[start:p.position], nil
+	}
+
+	start := p.position
+	p.position++
+
+	var value strings.Builder
+
+	for !p.done() {
+		r := p.peek()
+		p.position++
+
+		switch r {
+		case '"':
+			return value.String(), nil
+		case '\\':
+			if p.done() {
+				break
+			}
+
+			value.WriteRune(p.peek())
+			p.position++
+		default:
+			value.WriteRune(r)
+		}
+	}
+
+	return "", &SymbolError{Symbol: p.symbol, Position: start, Message: "unterminated quoted string"}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.readIdentifier()
+// Input        : none
+// Output       : A string representing the identifier at the parser's position
+// Side Effects : The parser's position is advanced past the identifier
+//
+// Abstract :
+// This method reads an identifier, i.e. the name of a modifier, transformation
+// or argument, which starts with a letter and continues with letters, digits
+// and underscores. An empty string is returned if there is no identifier.
+// -----------------------------------------------------------------------------
+func (p *symbolParser) readIdentifier() string {
+
+	start := p.position
+
+	if !unicode.IsLetter(p.peek()) {
+		return ""
+	}
+
+	p.readWhile(func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) })
+
+	return p.input[start:p.position]
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.readWhile()
+// Input        : keep - A function that reports whether to keep reading a rune
+// Output       : none
+// Side Effects : The parser's position is advanced past the runes read
+//
+// Abstract :
+// This method advances the parser for as long as keep returns true.
+// -----------------------------------------------------------------------------
+func (p *symbolParser) readWhile(keep func(rune) bool) {
+
+	for !p.done() && keep(p.peek()) {
+		p.position += len(string(p.peek()))
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.skipWhitespace()
+// Input        : none
+// Output       : none
+// Side Effects : The parser's position is advanced past any whitespace
+//
+// Abstract :
+// This method skips over whitespace at the parser's position.
+// -----------------------------------------------------------------------------
+func (p *symbolParser) skipWhitespace() {
+	p.readWhile(unicode.IsSpace)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.peek()
+// Input        : none
+// Output       :
+// The rune at the parser's position, or 0 if the input has been read
+//
+// Side Effects : none
+//
+// Abstract :
+// This method returns the next rune without advancing the parser.
+// -----------------------------------------------------------------------------
+func (p *symbolParser) peek() rune {
+
+	if p.done() {
+		return 0
+	}
+
+	for _, r := range p.input[p.position:] {
+		return r
+	}
+
+	return 0
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.done()
+// Input        : none
+// Output       :
+// A boolean value representing whether the whole input has been read
+//
+// Side Effects : none
+//
+// Abstract :
+// This method determines whether the parser has reached the end of its input.
+// -----------------------------------------------------------------------------
+func (p *symbolParser) done() bool {
+	return p.position >= len(p.input)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : symbolParser.newError()
+// Input        : message - A string describing a problem
+// Output       : A *SymbolError at the parser's position
+// Side Effects : none
+//
+// Abstract :
+// This method creates an error describing a problem at the parser's position.
+// -----------------------------------------------------------------------------
+func (p *symbolParser) newError(message string) error {
+	return &SymbolError{Symbol: p.symbol, Position: p.position, Message: message}
+}
+
+// -----------------------------------------------------------------------------
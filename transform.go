@@ -0,0 +1,432 @@
+// -----------------------------------------------------------------------------
+// File     : transform.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains the transformations that can be applied to values found
+// in the input XML by way of a find and replace symbol's modifiers, along with
+// the registry that transformations are looked up in.
+//
+// Example: <Patients.Patient.DateOfBirth transform=yearsElapsed>
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// REGISTRY
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : TransformFunc
+//
+// Abstract :
+// A TransformFunc implements a transformation. It receives a value found in
+// the input XML along with the arguments that the transformation was given,
+// and returns the transformed value or an error describing why the value
+// couldn't be transformed.
+// -----------------------------------------------------------------------------
+type TransformFunc func(value string, args Args) (string, error)
+
+// -----------------------------------------------------------------------------
+// Type     : Args
+//
+// Abstract :
+// Args holds the arguments that a transformation was given in parentheses
+// after its name. Arguments written as name=value are named, and all other
+// arguments are positional.
+//
+// Example: For transform=pad(10, side="left"), Positional contains '10' and
+// Named contains the key 'side' mapped to the value 'left'
+//
+// AsOf is filled in by the Converter rather than written in a symbol. It holds
+// the time that transformations such as yearsElapsed measure from, which is
+// the Converter's AsOf time if one was set and the current time otherwise.
+// -----------------------------------------------------------------------------
+type Args struct {
+	Positional []string          // Arguments given by position
+	Named      map[string]string // Arguments given by name
+	AsOf       time.Time         // The time that relative date transformations measure from
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Args.Get()
+// Input        :
+// name - A string representing the name of an argument
+// position - An integer representing the argument's position when it isn't
+// given by name, starting from 0
+//
+// Output       :
+// value - A string representing the argument's value
+// ok - A boolean value representing whether the argument was given
+//
+// Side Effects : none
+//
+// Abstract :
+// This method finds an argument that may be given either by name or by
+// position, preferring the named argument when both are given.
+//
+// Example: For both pad(10) and pad(width=10), Get("width", 0) returns '10'
+// -----------------------------------------------------------------------------
+func (a Args) Get(name string, position int) (string, bool) {
+
+	value, ok := a.Named[name]
+
+	if ok {
+		return value, true
+	}
+
+	if position >= 0 && position < len(a.Positional) {
+		return a.Positional[position], true
+	}
+
+	return "", false
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Args.Int()
+// Input        :
+// name - A string representing the name of an argument
+// position - An integer representing the argument's position when it isn't
+// given by name, starting from 0
+// fallback - An integer to use when the argument isn't given
+//
+// Output       :
+// value - An integer representing the argument's value
+// err - An error if the argument was given but isn't an integer
+//
+// Side Effects : none
+//
+// Abstract :
+// This method finds an integer argument in the same way as Get().
+// -----------------------------------------------------------------------------
+func (a Args) Int(name string, position int, fallback int) (int, error) {
+
+	value, ok := a.Get(name, position)
+
+	if !ok {
+		return fallback, nil
+	}
+
+	i, err := strconv.Atoi(strings.TrimSpace(value))
+
+	if err != nil {
+		return 0, fmt.Errorf("argument %s must be an integer, got '%s'", name, value)
+	}
+
+	return i, nil
+}
+
+// Package level variables
+var now = time.Now                                                                           // Clock used by date transformations, replaceable in tests
+var jsonNumberRegex = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`) // Numbers as JSON writes them
+var transformsMutex sync.RWMutex                                                             // Guards the transformation registry
+var transforms = map[string]TransformFunc{
+	"yearsElapsed":       yearsElapsed,
+	"monthsElapsed":      monthsElapsed,
+	"daysElapsed":        daysElapsed,
+	"dateFormat":         dateFormat,
+	"rfc3339":            rfc3339,
+	"unixTime":           unixTime,
+	"timeZone":           timeZone,
+	"datePart":           datePart,
+	"upper":              upper,
+	"lower":              lower,
+	"title":              title,
+	"trim":               trim,
+	"collapseWhitespace": collapseWhitespace,
+	"substring":          substring,
+	"pad":                pad,
+	"regexReplace":       regexReplace,
+	"truncate":           truncate,
+	"split":              split,
+}
+
+// The JSON types of the transformations whose results aren't strings, where
+// an empty type means a number when the result is one and a string otherwise
+var resultTypes = map[string]string{
+	"yearsElapsed":  "int",
+	"monthsElapsed": "int",
+	"daysElapsed":   "int",
+	"unixTime":      "int",
+	"datePart":      "",
+}
+
+// -----------------------------------------------------------------------------
+// Function     : RegisterTransform()
+// Input        :
+// name - A string representing the name used to refer to the transformation in
+// a find and replace symbol, e.g. yearsElapsed
+// fn - The function that implements the transformation
+//
+// Output       : none
+// Side Effects : The transformation is added to the registry
+//
+// Abstract :
+// This function makes a transformation available to every Converter. It is
+// intended to be called during initialization, before any conversions run.
+// Registering a nil function or a name that is already registered panics.
+// The transformation's results are strings, see RegisterTransformType().
+//
+// Example: gopherhole.RegisterTransform("medicalRecordNumber", formatMRN) makes
+// <Patients.Patient.MRN transform=medicalRecordNumber> call formatMRN
+// -----------------------------------------------------------------------------
+func RegisterTransform(name string, fn TransformFunc) {
+	RegisterTransformType(name, fn, "string")
+}
+
+// -----------------------------------------------------------------------------
+// Function     : RegisterTransformType()
+// Input        :
+// name - A string representing the name used to refer to the transformation in
+// a find and replace symbol, e.g. yearsElapsed
+// fn - The function that implements the transformation
+// typeName - A string representing the JSON type of the transformation's
+// results, as named by a type modifier, or an empty string for a number or
+// boolean when the result is written as one and a string otherwise
+//
+// Output       : none
+// Side Effects : The transformation is added to the registry
+//
+// Abstract :
+// This function registers a transformation in the same way as
+// RegisterTransform(), along with the type that a symbol's value takes when
+// the transformation is the last in its pipeline and the symbol has no type
+// modifier of its own. Registering an unknown type panics.
+//
+// Example: gopherhole.RegisterTransformType("riskScore", scoreRisk, "int")
+// makes <Patients.Patient.History transform=riskScore> produce a number
+// -----------------------------------------------------------------------------
+func RegisterTransformType(name string, fn TransformFunc, typeName string) {
+
+	transformsMutex.Lock()
+	defer transformsMutex.Unlock()
+
+	if fn == nil {
+		panic("gopherhole: RegisterTransform function is nil for " + name)
+	}
+
+	if typeName != "" && !types[typeName] {
+		panic("gopherhole: RegisterTransformType given unknown type " + typeName + " for " + name)
+	}
+
+	_, ok := transforms[name]
+
+	if ok {
+		panic("gopherhole: RegisterTransform called twice for " + name)
+	}
+
+	transforms[name] = fn
+	resultTypes[name] = typeName
+}
+
+// -----------------------------------------------------------------------------
+// Function     : LookupTransform()
+// Input        : name - A string representing the name of a transformation
+// Output       :
+// fn - The function that implements the transformation
+// ok - A boolean value representing whether the transformation is registered
+//
+// Side Effects : none
+//
+// Abstract :
+// This function finds a transformation in the registry by name.
+// -----------------------------------------------------------------------------
+func LookupTransform(name string) (TransformFunc, bool) {
+
+	transformsMutex.RLock()
+	defer transformsMutex.RUnlock()
+
+	fn, ok := transforms[name]
+
+	return fn, ok
+}
+
+// -----------------------------------------------------------------------------
+// Function     : applyModifiers()
+// Input        :
+// value - A string representing a value found in the input XML
+// modifiers - The list of modifiers written within a find and replace symbol
+// asOf - The time that relative date transformations measure from
+//
+// Output       :
+// transformed - A string representing the value after any modifiers have been
+// applied
+// err - A *TransformError describing the transformation that is unknown or
+// failed, if any, with its Symbol left for the caller to fill in
+//
+// Side Effects : none
+//
+// Abstract :
+// This function applies the modifiers of a find and replace symbol to a value
+// that was found in the input XML before the value replaces the symbol. Each
+// transformation in the symbol's pipeline is looked up in the registry and
+// applied in turn, receiving the output of the transformation before it along
+// with its own arguments. If a transformation is unknown or fails, the
+// pipeline stops.
+// -----------------------------------------------------------------------------
+func applyModifiers(value string, modifiers Modifiers, asOf time.Time) (string, *TransformError) {
+
+	transformed := value
+
+	for _, transformation := range modifiers.Transformations() {
+		fn, ok := LookupTransform(transformation.Name)
+
+		if !ok {
+			return "", &TransformError{Transform: transformation.Name, Value: transformed, Err: errors.New("unknown transformation")}
+		}
+
+		args := transformation.Args
+		args.AsOf = asOf
+
+		result, err := fn(transformed, args)
+
+		if err != nil {
+			return "", &TransformError{Transform: transformation.Name, Value: transformed, Err: err}
+		}
+
+		transformed = result
+	}
+
+	return transformed, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// TYPES
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : resultType()
+// Input        : transformations - The pipeline of a symbol's transform modifier
+// Output       :
+// A string representing the type that the pipeline's result is converted to
+// when the symbol has no type modifier, as accepted by convertType()
+//
+// Side Effects : none
+//
+// Abstract :
+// This function finds the type of a pipeline's result, which is the type
+// produced by its last transformation. Transformations such as yearsElapsed
+// produce numbers, as do those registered with RegisterTransformType() for a
+// numeric type, and every other transformation produces a string. Without
+// any transformations, the value taken from the XML is typed automatically.
+//
+// Example: yearsElapsed produces an int, while yearsElapsed|pad(3) and trim
+// produce strings
+// -----------------------------------------------------------------------------
+func resultType(transformations []Transformation) string {
+
+	if len(transformations) == 0 {
+		return ""
+	}
+
+	transformsMutex.RLock()
+	defer transformsMutex.RUnlock()
+
+	typeName, ok := resultTypes[transformations[len(transformations)-1].Name]
+
+	if !ok {
+		return "string"
+	}
+
+	return typeName
+}
+
+// -----------------------------------------------------------------------------
+// Function     : convertType()
+// Input        :
+// value - A string representing a value after any transformations
+// typeName - A string representing the output type named by a symbol's type
+// modifier, or an empty string if the symbol has no type modifier
+//
+// Output       :
+// typed - A typeless value representing the value converted to the given type
+// err - An error if the value can't be converted to the given type
+//
+// Side Effects : none
+//
+// Abstract :
+// This function converts a value into the JSON type named by a type modifier.
+// The supported types are int, float, bool, string and nullIfEmpty, which
+// produces a string or null if the value is empty. An empty value converted to
+// an int, a float or a bool becomes null.
+//
+// When typeName is empty, values that are written the way JSON writes numbers
+// and booleans become numbers and booleans, and all other values remain
+// strings. A value such as 00123 remains a string because JSON numbers can't
+// have leading zeros.
+// -----------------------------------------------------------------------------
+func convertType(value string, typeName string) (interface{}, error) {
+
+	switch typeName {
+	case "":
+		if value == "true" || value == "false" {
+			return value == "true", nil
+		}
+
+		if jsonNumberRegex.MatchString(value) {
+			return json.Number(value), nil
+		}
+
+		return value, nil
+
+	case "string":
+		return value, nil
+
+	case "nullIfEmpty":
+		if value == "" {
+			return nil, nil
+		}
+
+		return value, nil
+
+	case "int", "float", "bool":
+		if value == "" {
+			return nil, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %s", typeName)
+	}
+
+	var err error
+
+	switch typeName {
+	case "int":
+		var i int64
+		i, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+
+		if err == nil {
+			return i, nil
+		}
+	case "float":
+		var f float64
+		f, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+		if err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f, nil
+		}
+	case "bool":
+		var b bool
+		b, err = strconv.ParseBool(strings.TrimSpace(value))
+
+		if err == nil {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("'%s' isn't a valid %s", value, typeName)
+}
+
+// -----------------------------------------------------------------------------
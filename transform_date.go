@@ -0,0 +1,543 @@
+// -----------------------------------------------------------------------------
+// File     : transform_date.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains gopherhole's built-in date and time transformations,
+// which reformat dates, convert them between time zones, measure the time that
+// has elapsed since them and pick out their parts.
+//
+// Each date transformation accepts the optional arguments from, the layout of
+// the value found in the input XML, and zone, the time zone of values that
+// don't include one. Layouts are written the way Go writes them, e.g.
+// 01/02/2006, or by the name of one of Go's standard layouts, e.g. RFC3339.
+// Values are read as 2006-01-02 in UTC unless told otherwise.
+//
+// Example: <Patients.Patient.Visit transform=dateFormat(from="01/02/2006", to="2006-01-02")>
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zone names work without a system time zone database
+)
+
+// Package level variables
+var layouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// -----------------------------------------------------------------------------
+// FORMATTING
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : dateFormat()
+// Input        :
+// value - A string representing a date
+// args - The to argument, the layout to write the date in, along with the
+// optional from and zone arguments
+//
+// Output       :
+// The date written in the given layout
+// An error if the date can't be read or no layout was given
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the dateFormat transformation, which rewrites a date from
+// one layout into another, e.g. dateFormat(from="01/02/2006", to="2006-01-02")
+// turns '07/15/1985' into '1985-07-15'.
+// -----------------------------------------------------------------------------
+func dateFormat(value string, args Args) (string, error) {
+
+	to, ok := args.Get("to", 0)
+
+	if !ok {
+		return "", errors.New("dateFormat requires a layout to format the date with")
+	}
+
+	t, err := parseDate(value, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	return t.Format(layoutByName(to)), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : rfc3339()
+// Input        :
+// value - A string representing a date
+// args - The optional from and zone arguments
+//
+// Output       :
+// The date written in RFC 3339 format
+// An error if the date can't be read
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the rfc3339 transformation, e.g. rfc3339(zone="UTC") turns
+// '1985-07-15' into '1985-07-15T00:00:00Z'.
+// -----------------------------------------------------------------------------
+func rfc3339(value string, args Args) (string, error) {
+
+	t, err := parseDate(value, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	return t.Format(time.RFC3339), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : unixTime()
+// Input        :
+// value - A string representing a date
+// args - The optional unit argument, either seconds or milliseconds, along
+// with the optional from and zone arguments
+//
+// Output       :
+// The number of seconds or milliseconds between the Unix epoch and the date
+// An error if the date can't be read or the unit is unknown
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the unixTime transformation, e.g. unixTime turns
+// '1985-07-15' into '490233600'.
+// -----------------------------------------------------------------------------
+func unixTime(value string, args Args) (string, error) {
+
+	t, err := parseDate(value, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	unit, ok := args.Get("unit", 0)
+
+	if !ok {
+		unit = "seconds"
+	}
+
+	switch unit {
+	case "seconds":
+		return strconv.FormatInt(t.Unix(), 10), nil
+	case "milliseconds":
+		return strconv.FormatInt(t.UnixMilli(), 10), nil
+	}
+
+	return "", fmt.Errorf("unixTime unit must be seconds or milliseconds, got '%s'", unit)
+}
+
+// -----------------------------------------------------------------------------
+// Function     : timeZone()
+// Input        :
+// value - A string representing a date and time
+// args - The to argument, the name of the time zone to convert into, along
+// with the optional layout argument and the optional from and zone arguments
+//
+// Output       :
+// The same moment in time as seen in the given time zone, written in the
+// given layout or in RFC 3339 format
+// An error if the date can't be read or the time zone is unknown
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the timeZone transformation, which shifts a time into
+// another time zone, e.g. timeZone(to="America/New_York", from=RFC3339) turns
+// '2025-01-15T17:00:00Z' into '2025-01-15T12:00:00-05:00'.
+// -----------------------------------------------------------------------------
+func timeZone(value string, args Args) (string, error) {
+
+	to, ok := args.Get("to", 0)
+
+	if !ok {
+		return "", errors.New("timeZone requires a time zone to convert into")
+	}
+
+	location, err := time.LoadLocation(to)
+
+	if err != nil {
+		return "", fmt.Errorf("unknown time zone '%s'", to)
+	}
+
+	t, err := parseDate(value, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	layout, ok := args.Get("layout", 1)
+
+	if !ok {
+		layout = time.RFC3339
+	}
+
+	return t.In(location).Format(layoutByName(layout)), nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// ELAPSED TIME
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : YearsElapsed()
+// Input        :
+// date - A string representing a date to be converted into the number of
+// years that have elapsed since the input date
+//
+// Output       :
+// age - An integer representing the number of years that have elapsed since the
+// input date
+//
+// Side Effects : none
+//
+// Abstract :
+// This function takes in a date string and calculates the number of years that
+// have elapsed since that date. A date that can't be read is treated as the
+// zero date, January 1 of year 1, rather than reported, so it gives the number
+// of years elapsed since then, e.g. 2024 in February 2025.
+//
+// Deprecated: Use the yearsElapsed transformation, which returns a
+// *TransformError for a date that can't be read, e.g.
+// <Patients.Patient.DateOfBirth transform=yearsElapsed>.
+// -----------------------------------------------------------------------------
+func YearsElapsed(date string) int {
+
+	d, _ := time.Parse(time.DateOnly, date)
+
+	return monthsBetween(d, now()) / 12
+}
+
+// -----------------------------------------------------------------------------
+// Function     : yearsElapsed()
+// Input        :
+// value - A string representing a date
+// args - The optional from, zone and asOf arguments
+//
+// Output       :
+// A string representing the number of whole years that have elapsed between
+// the date and the as-of date
+// An error if either date can't be read
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the yearsElapsed transformation, which replaces a date with
+// the number of years that have elapsed since that date, e.g. a person's age
+// given their date of birth. Time is measured up to the asOf argument if one
+// is given, and up to the Converter's as-of time otherwise.
+// -----------------------------------------------------------------------------
+func yearsElapsed(value string, args Args) (string, error) {
+
+	from, to, err := parseElapsed(value, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(monthsBetween(from, to) / 12), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : monthsElapsed()
+// Input        :
+// value - A string representing a date
+// args - The optional from, zone and asOf arguments
+//
+// Output       :
+// A string representing the number of whole months that have elapsed between
+// the date and the as-of date
+// An error if either date can't be read
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the monthsElapsed transformation, which works in the same
+// way as yearsElapsed but counts months.
+// -----------------------------------------------------------------------------
+func monthsElapsed(value string, args Args) (string, error) {
+
+	from, to, err := parseElapsed(value, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(monthsBetween(from, to)), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : daysElapsed()
+// Input        :
+// value - A string representing a date
+// args - The optional from, zone and asOf arguments
+//
+// Output       :
+// A string representing the number of calendar days between the date and the
+// as-of date
+// An error if either date can't be read
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the daysElapsed transformation, which works in the same
+// way as yearsElapsed but counts days.
+// -----------------------------------------------------------------------------
+func daysElapsed(value string, args Args) (string, error) {
+
+	from, to, err := parseElapsed(value, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	// Count calendar days so that daylight saving time doesn't shorten a day
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	return strconv.Itoa(int(toDay.Sub(fromDay).Hours() / 24)), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : monthsBetween()
+// Input        :
+// from - The earlier of two times
+// to - The later of two times
+//
+// Output       :
+// An integer representing the number of whole months between the times
+//
+// Side Effects : none
+//
+// Abstract :
+// This function calculates the number of whole months that have passed
+// between two times. A month has passed once the day of the month of the
+// earlier time has been reached, so the function also gives whole years, e.g.
+// a person's age, when its result is divided by 12.
+// -----------------------------------------------------------------------------
+func monthsBetween(from time.Time, to time.Time) int {
+
+	to = to.In(from.Location())
+	months := (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+
+	// Has the given day of the month happened yet this month?
+	if months > 0 && to.Day() < from.Day() {
+		months--
+	} else if months < 0 && to.Day() > from.Day() {
+		months++
+	}
+
+	return months
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// PARTS
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : datePart()
+// Input        :
+// value - A string representing a date
+// args - The part argument, along with the optional from and zone arguments
+//
+// Output       :
+// A string representing the requested part of the date
+// An error if the date can't be read or the part is unknown
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the datePart transformation, which picks a single part out
+// of a date. The parts are year, quarter, month, monthName, day, dayOfYear,
+// weekday, hour, minute and second, e.g. datePart(month) turns '1985-07-15'
+// into '7' and datePart(monthName) turns it into 'July'.
+// -----------------------------------------------------------------------------
+func datePart(value string, args Args) (string, error) {
+
+	part, ok := args.Get("part", 0)
+
+	if !ok {
+		return "", errors.New("datePart requires a part")
+	}
+
+	t, err := parseDate(value, args)
+
+	if err != nil {
+		return "", err
+	}
+
+	switch part {
+	case "year":
+		return strconv.Itoa(t.Year()), nil
+	case "quarter":
+		return strconv.Itoa((int(t.Month())-1)/3 + 1), nil
+	case "month":
+		return strconv.Itoa(int(t.Month())), nil
+	case "monthName":
+		return t.Month().String(), nil
+	case "day":
+		return strconv.Itoa(t.Day()), nil
+	case "dayOfYear":
+		return strconv.Itoa(t.YearDay()), nil
+	case "weekday":
+		return t.Weekday().String(), nil
+	case "hour":
+		return strconv.Itoa(t.Hour()), nil
+	case "minute":
+		return strconv.Itoa(t.Minute()), nil
+	case "second":
+		return strconv.Itoa(t.Second()), nil
+	}
+
+	return "", fmt.Errorf("unknown date part '%s'", part)
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// UTILITY
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : parseDate()
+// Input        :
+// value - A string representing a date
+// args - The optional from and zone arguments
+//
+// Output       :
+// The date that the value represents
+// An error if the date can't be read or the time zone is unknown
+//
+// Side Effects : none
+//
+// Abstract :
+// This function reads a date using the layout given by the from argument, or
+// 2006-01-02 if there is none. Dates that don't include a time zone are read
+// in the zone given by the zone argument, or in UTC if there is none.
+// -----------------------------------------------------------------------------
+func parseDate(value string, args Args) (time.Time, error) {
+
+	layout, ok := args.Named["from"]
+
+	if !ok {
+		layout = time.DateOnly
+	}
+
+	location := time.UTC
+	zone, ok := args.Named["zone"]
+
+	if ok {
+		var err error
+		location, err = time.LoadLocation(zone)
+
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone '%s'", zone)
+		}
+	}
+
+	t, err := time.ParseInLocation(layoutByName(layout), strings.TrimSpace(value), location)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse date '%s' with layout '%s'", value, layout)
+	}
+
+	return t, nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : parseElapsed()
+// Input        :
+// value - A string representing a date
+// args - The optional from, zone and asOf arguments
+//
+// Output       :
+// from - The date that the value represents
+// to - The as-of date that elapsed time is measured up to
+// err - An error if either date can't be read
+//
+// Side Effects : none
+//
+// Abstract :
+// This function reads the two dates that elapsed time transformations measure
+// between. The asOf argument is read in the same layout and zone as the value,
+// and the Converter's as-of time is used if it isn't given.
+// -----------------------------------------------------------------------------
+func parseElapsed(value string, args Args) (time.Time, time.Time, error) {
+
+	from, err := parseDate(value, args)
+
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	asOf, ok := args.Named["asOf"]
+
+	if !ok {
+		to := args.AsOf
+
+		if to.IsZero() {
+			to = now()
+		}
+
+		return from, to, nil
+	}
+
+	to, err := parseDate(asOf, args)
+
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return from, to, nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : layoutByName()
+// Input        : name - A string representing a layout or a layout's name
+// Output       : A string representing a layout
+// Side Effects : none
+//
+// Abstract :
+// This function looks up one of Go's standard layouts by name, e.g. RFC3339,
+// and otherwise treats the given name as a layout.
+// -----------------------------------------------------------------------------
+func layoutByName(name string) string {
+
+	layout, ok := layouts[name]
+
+	if ok {
+		return layout
+	}
+
+	return name
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,433 @@
+// -----------------------------------------------------------------------------
+// File     : transform_string.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains gopherhole's built-in string transformations, which
+// change the case, whitespace, length and content of text found in the input
+// XML.
+//
+// Example: <Patients.Patient.LastName transform=trim|upper>
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Package level variables
+var regexCache sync.Map // Compiled regexReplace patterns by pattern text
+
+// -----------------------------------------------------------------------------
+// CASE
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : upper()
+// Input        :
+// value - A string to be transformed
+// args - The arguments that the transformation was given, which are unused
+//
+// Output       : The value with every letter in upper case
+// Side Effects : none
+//
+// Abstract :
+// This function is the upper transformation, e.g. 'Doe' becomes 'DOE'.
+// -----------------------------------------------------------------------------
+func upper(value string, args Args) (string, error) {
+	return strings.ToUpper(value), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : lower()
+// Input        :
+// value - A string to be transformed
+// args - The arguments that the transformation was given, which are unused
+//
+// Output       : The value with every letter in lower case
+// Side Effects : none
+//
+// Abstract :
+// This function is the lower transformation, e.g. 'Doe' becomes 'doe'.
+// -----------------------------------------------------------------------------
+func lower(value string, args Args) (string, error) {
+	return strings.ToLower(value), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : title()
+// Input        :
+// value - A string to be transformed
+// args - The arguments that the transformation was given, which are unused
+//
+// Output       :
+// The value with the first letter of each word in upper case and every other
+// letter in lower case
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the title transformation. A word begins with any letter
+// that doesn't follow another letter, so 'mary-jane o'NEIL' becomes
+// 'Mary-Jane O'Neil'.
+// -----------------------------------------------------------------------------
+func title(value string, args Args) (string, error) {
+
+	var builder strings.Builder
+	previous := ' '
+
+	for _, r := range value {
+		if unicode.IsLetter(previous) {
+			builder.WriteRune(unicode.ToLower(r))
+		} else {
+			builder.WriteRune(unicode.ToTitle(r))
+		}
+
+		previous = r
+	}
+
+	return builder.String(), nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// WHITESPACE
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : trim()
+// Input        :
+// value - A string to be transformed
+// args - An optional argument, chars, listing the characters to be removed
+//
+// Output       : The value without leading or trailing characters
+// Side Effects : none
+//
+// Abstract :
+// This function is the trim transformation, which removes whitespace from
+// both ends of a value. If the chars argument is given, those characters are
+// removed instead, e.g. trim("0") turns '00120' into '12'.
+// -----------------------------------------------------------------------------
+func trim(value string, args Args) (string, error) {
+
+	chars, ok := args.Get("chars", 0)
+
+	if ok {
+		return strings.Trim(value, chars), nil
+	}
+
+	return strings.TrimSpace(value), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : collapseWhitespace()
+// Input        :
+// value - A string to be transformed
+// args - The arguments that the transformation was given, which are unused
+//
+// Output       :
+// The value with each run of whitespace replaced by a single space and no
+// leading or trailing whitespace
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the collapseWhitespace transformation, which tidies text
+// that was wrapped or indented in the input XML.
+// -----------------------------------------------------------------------------
+func collapseWhitespace(value string, args Args) (string, error) {
+	return strings.Join(strings.Fields(value), " "), nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// LENGTH
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : substring()
+// Input        :
+// value - A string to be transformed
+// args - The start argument, and an optional end argument
+//
+// Output       :
+// The characters of the value from start up to, but not including, end
+// An error if the arguments aren't integers
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the substring transformation. Positions count characters
+// rather than bytes and start from 0. Negative positions count back from the
+// end of the value, and positions beyond either end of the value are moved to
+// that end, e.g. substring(0, 3) turns 'Johnson' into 'Joh' and substring(-3)
+// turns it into 'son'.
+// -----------------------------------------------------------------------------
+func substring(value string, args Args) (string, error) {
+
+	runes := []rune(value)
+
+	start, err := args.Int("start", 0, 0)
+
+	if err != nil {
+		return "", err
+	}
+
+	end, err := args.Int("end", 1, len(runes))
+
+	if err != nil {
+		return "", err
+	}
+
+	start = clampPosition(start, len(runes))
+	end = clampPosition(end, len(runes))
+
+	if start >= end {
+		return "", nil
+	}
+
+	return string(runes[start:end]), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : pad()
+// Input        :
+// value - A string to be transformed
+// args - The width argument, and optional fill and side arguments
+//
+// Output       :
+// The value lengthened to the given width
+// An error if the arguments are invalid
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the pad transformation, which adds the fill character,
+// a space by default, to one side of a value until it is width characters
+// long. The side is either left, the default, or right. Values that are
+// already long enough are left as they are, e.g. pad(5, fill="0") turns '123'
+// into '00123'.
+// -----------------------------------------------------------------------------
+func pad(value string, args Args) (string, error) {
+
+	width, err := args.Int("width", 0, -1)
+
+	if err != nil {
+		return "", err
+	}
+
+	if width < 0 {
+		return "", errors.New("pad requires a width")
+	}
+
+	fill, ok := args.Get("fill", 1)
+
+	if !ok {
+		fill = " "
+	}
+
+	if utf8.RuneCountInString(fill) != 1 {
+		return "", fmt.Errorf("pad requires a single fill character, got '%s'", fill)
+	}
+
+	side, ok := args.Get("side", 2)
+
+	if !ok {
+		side = "left"
+	}
+
+	padding := strings.Repeat(fill, max(0, width-utf8.RuneCountInString(value)))
+
+	switch side {
+	case "left":
+		return padding + value, nil
+	case "right":
+		return value + padding, nil
+	}
+
+	return "", fmt.Errorf("pad side must be left or right, got '%s'", side)
+}
+
+// -----------------------------------------------------------------------------
+// Function     : truncate()
+// Input        :
+// value - A string to be transformed
+// args - The length argument, and an optional suffix argument
+//
+// Output       :
+// The value shortened to at most the given length
+// An error if the arguments are invalid
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the truncate transformation. Values longer than length
+// characters are cut short and the suffix, if one is given, is added to the
+// end. The suffix counts towards the length, e.g. truncate(8, "...") turns
+// 'Penicillin' into 'Penic...'.
+// -----------------------------------------------------------------------------
+func truncate(value string, args Args) (string, error) {
+
+	length, err := args.Int("length", 0, -1)
+
+	if err != nil {
+		return "", err
+	}
+
+	if length < 0 {
+		return "", errors.New("truncate requires a length")
+	}
+
+	suffix, _ := args.Get("suffix", 1)
+	runes := []rune(value)
+
+	if len(runes) <= length {
+		return value, nil
+	}
+
+	suffixRunes := []rune(suffix)
+
+	if len(suffixRunes) >= length {
+		return string(suffixRunes[:length]), nil
+	}
+
+	return string(runes[:length-len(suffixRunes)]) + suffix, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// CONTENT
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : regexReplace()
+// Input        :
+// value - A string to be transformed
+// args - The pattern and replacement arguments
+//
+// Output       :
+// The value with every match of the pattern replaced
+// An error if the arguments are invalid
+//
+// Side Effects : Compiled patterns are cached for reuse
+//
+// Abstract :
+// This function is the regexReplace transformation. The pattern uses Go's
+// regular expression syntax, and the replacement may refer to capture groups
+// with $1 or ${name}, e.g. regexReplace("[^0-9]", "") turns '(555) 123-4567'
+// into '5551234567'.
+// -----------------------------------------------------------------------------
+func regexReplace(value string, args Args) (string, error) {
+
+	pattern, ok := args.Get("pattern", 0)
+
+	if !ok {
+		return "", errors.New("regexReplace requires a pattern")
+	}
+
+	replacement, ok := args.Get("replacement", 1)
+
+	if !ok {
+		return "", errors.New("regexReplace requires a replacement")
+	}
+
+	cached, ok := regexCache.Load(pattern)
+
+	if !ok {
+		compiled, err := regexp.Compile(pattern)
+
+		if err != nil {
+			return "", fmt.Errorf("invalid regexReplace pattern: %w", err)
+		}
+
+		cached, _ = regexCache.LoadOrStore(pattern, compiled)
+	}
+
+	return cached.(*regexp.Regexp).ReplaceAllString(value, replacement), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : split()
+// Input        :
+// value - A string to be transformed
+// args - The separator argument, and an optional index argument
+//
+// Output       :
+// The part of the value at the given index after splitting it on separator
+// An error if the arguments are invalid
+//
+// Side Effects : none
+//
+// Abstract :
+// This function is the split transformation, which picks one part out of a
+// value that holds several. The index starts from 0, the default, and negative
+// indexes count back from the last part. An index beyond the number of parts
+// produces an empty value, e.g. split(",", 1) turns 'Doe,John' into 'John' and
+// split(" ", -1) turns 'Mary Ann Smith' into 'Smith'.
+// -----------------------------------------------------------------------------
+func split(value string, args Args) (string, error) {
+
+	separator, ok := args.Get("separator", 0)
+
+	if !ok || separator == "" {
+		return "", errors.New("split requires a separator")
+	}
+
+	index, err := args.Int("index", 1, 0)
+
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Split(value, separator)
+
+	if index < 0 {
+		index += len(parts)
+	}
+
+	if index < 0 || index >= len(parts) {
+		return "", nil
+	}
+
+	return parts[index], nil
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// UTILITY
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : clampPosition()
+// Input        :
+// position - An integer representing a position within a value, which counts
+// back from the end of the value when negative
+// length - An integer representing the length of the value
+//
+// Output       : An integer representing a position between 0 and length
+// Side Effects : none
+//
+// Abstract :
+// This function converts a position that may be negative or out of bounds
+// into a position that can be used to slice a value.
+// -----------------------------------------------------------------------------
+func clampPosition(position int, length int) int {
+
+	if position < 0 {
+		position += length
+	}
+
+	return min(max(position, 0), length)
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,421 @@
+package gopherhole
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupTransform(t *testing.T) {
+
+	var tests = []struct {
+		name string
+		want bool
+	}{
+		{"yearsElapsed", true},
+		{"YearsElapsed", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, got := LookupTransform(test.name)
+
+			if got != test.want {
+				t.Errorf("Got %t, wanted %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRegisterTransform(t *testing.T) {
+
+	RegisterTransform("testSuffix", func(value string, args Args) (string, error) {
+		if args.Named["suffix"] == "" {
+			return "", errors.New("no suffix given")
+		}
+
+		return value + " " + args.Named["suffix"], nil
+	})
+
+	config := `{
+		"Patients": [
+			{
+				"name": "<Patients.Patient.Name transform=testSuffix(suffix=Jr)>"
+			}
+		]
+	}`
+
+	input := `<Patients><Patient><Name>John</Name></Patient></Patients>`
+	want := `{"Patients":[{"name":"John Jr"}]}`
+
+	converter, err := NewConverter(strings.NewReader(config))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	var output bytes.Buffer
+	err = converter.Convert(strings.NewReader(input), &output)
+
+	if err != nil {
+		t.Fatalf("Got error %v converting the input", err)
+	}
+
+	got := compactJSON(t, output.String())
+
+	if got != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestRegisterTransformType(t *testing.T) {
+
+	RegisterTransformType("testLength", func(value string, args Args) (string, error) {
+		return strconv.Itoa(len(value)), nil
+	}, "int")
+
+	RegisterTransformType("testEcho", func(value string, args Args) (string, error) {
+		return value, nil
+	}, "")
+
+	var tests = []struct {
+		symbol string
+		want   string
+	}{
+		{"<Patients.Patient.Name transform=testLength>", `4`},
+		{"<Patients.Patient.Name transform=testLength type=string>", `"4"`},
+		{"<Patients.Patient.Name transform=testLength|trim>", `"4"`},
+		{"<Patients.Patient.Score transform=testEcho>", `7`},
+		{"<Patients.Patient.Name transform=testEcho>", `"John"`},
+	}
+
+	input := `<Patients><Patient><Name>John</Name><Score>7</Score></Patient></Patients>`
+
+	for _, test := range tests {
+		t.Run(test.symbol, func(t *testing.T) {
+			config, _ := json.Marshal(map[string]interface{}{"Patients": []interface{}{map[string]string{"value": test.symbol}}})
+			got := convertString(t, string(config), input, nil)
+			want := `{"Patients":[{"value":` + test.want + `}]}`
+
+			if got != want {
+				t.Errorf("Got %s, wanted %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRegisterTransformUnknownType(t *testing.T) {
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Got no panic, wanted a panic for an unknown type")
+		}
+	}()
+
+	RegisterTransformType("testUnknownType", trim, "decimal")
+}
+
+func TestRegisterTransformTwice(t *testing.T) {
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Got no panic, wanted a panic for a duplicate registration")
+		}
+	}()
+
+	RegisterTransform("yearsElapsed", yearsElapsed)
+}
+
+func TestTransformPipeline(t *testing.T) {
+
+	RegisterTransform("testTrim", func(value string, args Args) (string, error) {
+		return strings.TrimSpace(value), nil
+	})
+	RegisterTransform("testUpper", func(value string, args Args) (string, error) {
+		return strings.ToUpper(value), nil
+	})
+	RegisterTransform("testQuote", func(value string, args Args) (string, error) {
+		return "'" + value + "'", nil
+	})
+
+	config := `{
+		"Patients": [
+			{
+				"piped": "<Patients.Patient.Name transform=testTrim|testUpper|testQuote>",
+				"repeated": "<Patients.Patient.Name transform=testTrim transform=testQuote transform=testUpper>",
+				"reordered": "<Patients.Patient.Name transform=testQuote|testTrim>"
+			}
+		]
+	}`
+
+	input := `<Patients><Patient><Name>  john  </Name></Patient></Patients>`
+	want := `{"Patients":[{"piped":"'JOHN'","repeated":"'JOHN'","reordered":"'  john  '"}]}`
+
+	converter, err := NewConverter(strings.NewReader(config))
+
+	if err != nil {
+		t.Fatalf("Got error %v creating the converter", err)
+	}
+
+	var output bytes.Buffer
+	err = converter.Convert(strings.NewReader(input), &output)
+
+	if err != nil {
+		t.Fatalf("Got error %v converting the input", err)
+	}
+
+	got := compactJSON(t, output.String())
+
+	if got != want {
+		t.Errorf("Got %s, wanted %s", got, want)
+	}
+}
+
+func TestConvertTransformErrors(t *testing.T) {
+
+	RegisterTransform("testFail", func(value string, args Args) (string, error) {
+		return "", errors.New("always fails")
+	})
+
+	var tests = []struct {
+		name          string
+		template      string
+		wantTransform string
+		wantValue     string
+	}{
+		{"failed", `<Patients.Patient.Name transform=testFail>`, "testFail", "John"},
+		{"embedded", `Dr. <Patients.Patient.Name transform=testFail>`, "testFail", "John"},
+		{"repeated", `<Patients.Patient.Name repeat transform=testFail>`, "testFail", "John"},
+		{"type", `<Patients.Patient.Name type=int>`, "type=int", "John"},
+		{"nested", `{"$repeat": "Patients.Patient.Name", "name": "<Patients.Patient.Name type=float>"}`, "type=float", "John"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			definition := strconv.Quote(test.template)
+
+			if strings.HasPrefix(test.template, "{") {
+				definition = test.template
+			}
+
+			converter, err := NewConverter(strings.NewReader(`{"Patients": [{"name": ` + definition + `}]}`))
+
+			if err != nil {
+				t.Fatalf("Got error %v creating the converter", err)
+			}
+
+			input := `<Patients><Patient><Name>John</Name></Patient></Patients>`
+			err = converter.Convert(strings.NewReader(input), io.Discard)
+
+			var transformError *TransformError
+
+			if !errors.As(err, &transformError) {
+				t.Fatalf("Got error %v, wanted a *TransformError", err)
+			}
+
+			if transformError.Transform != test.wantTransform || transformError.Value != test.wantValue {
+				t.Errorf("Got transform %s of value %s, wanted transform %s of value %s", transformError.Transform, transformError.Value, test.wantTransform, test.wantValue)
+			}
+
+			if transformError.Symbol == "" {
+				t.Errorf("Got no symbol in error %v", err)
+			}
+		})
+	}
+}
+
+func TestStringTransforms(t *testing.T) {
+
+	var tests = []struct {
+		transform string
+		input     string
+		want      string
+		wantErr   bool
+	}{
+		{"upper", "Doe", "DOE", false},
+		{"lower", "Doe", "doe", false},
+		{"title", "mary-jane o'NEIL", "Mary-Jane O'Neil", false},
+		{"title", "éLODIE", "Élodie", false},
+		{"trim", "  John \n", "John", false},
+		{`trim("0")`, "00120", "12", false},
+		{`trim(chars="-*")`, "*-John-*", "John", false},
+		{"collapseWhitespace", "  John \n\t Doe  ", "John Doe", false},
+		{"substring(0, 3)", "Johnson", "Joh", false},
+		{"substring(-3)", "Johnson", "son", false},
+		{"substring(start=1, end=-1)", "Johnson", "ohnso", false},
+		{"substring(2, 100)", "Zoë", "ë", false},
+		{"substring(5, 2)", "Johnson", "", false},
+		{"substring(a)", "Johnson", "", true},
+		{`pad(5, fill="0")`, "123", "00123", false},
+		{`pad(5, side=right, fill=".")`, "abc", "abc..", false},
+		{"pad(2)", "abc", "abc", false},
+		{"pad", "abc", "", true},
+		{`pad(5, fill="00")`, "abc", "", true},
+		{"pad(5, side=up)", "abc", "", true},
+		{`regexReplace("[^0-9]", "")`, "(555) 123-4567", "5551234567", false},
+		{`regexReplace("(\\w+), (\\w+)", "$2 $1")`, "Doe, John", "John Doe", false},
+		{`regexReplace("(")`, "abc", "", true},
+		{`regexReplace("(", "")`, "abc", "", true},
+		{`truncate(8, "...")`, "Penicillin", "Penic...", false},
+		{"truncate(4)", "Penicillin", "Peni", false},
+		{"truncate(20)", "Penicillin", "Penicillin", false},
+		{`truncate(2, "...")`, "Penicillin", "..", false},
+		{"truncate", "Penicillin", "", true},
+		{`split(",", 1)`, "Doe,John", "John", false},
+		{`split(" ", -1)`, "Mary Ann Smith", "Smith", false},
+		{`split(separator=",")`, "Doe,John", "Doe", false},
+		{`split(",", 5)`, "Doe,John", "", false},
+		{"split", "Doe,John", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.transform+" "+test.input, func(t *testing.T) {
+			symbol, err := ParseFindAndReplaceSymbol("<Value transform=" + test.transform + ">")
+
+			if err != nil {
+				t.Fatalf("Got error %v parsing the transformation", err)
+			}
+
+			transformation := symbol.Modifiers.Transformations()[0]
+			fn, ok := LookupTransform(transformation.Name)
+
+			if !ok {
+				t.Fatalf("Transformation %s isn't registered", transformation.Name)
+			}
+
+			got, err := fn(test.input, transformation.Args)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Got %s, wanted an error", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %v, wanted %s", err, test.want)
+			}
+
+			if got != test.want {
+				t.Errorf("Got %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDateTransforms(t *testing.T) {
+
+	asOf := time.Date(2025, time.February, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		transform string
+		input     string
+		want      string
+		wantErr   bool
+	}{
+		{"yearsElapsed", "1985-07-15", "39", false},
+		{"yearsElapsed", "1985-02-01", "40", false},
+		{"yearsElapsed", "2024-02-29", "0", false},
+		{`yearsElapsed(asOf="2025-03-01")`, "2024-02-29", "1", false},
+		{`yearsElapsed(from="01/02/2006")`, "07/15/1985", "39", false},
+		{"yearsElapsed", "07/15/1985", "", true},
+		{"monthsElapsed", "2024-11-15", "2", false},
+		{"monthsElapsed", "2025-01-02", "0", false},
+		{"daysElapsed", "2025-01-01", "31", false},
+		{`daysElapsed(asOf="2024-03-01")`, "2024-02-01", "29", false},
+		{`dateFormat(from="01/02/2006", to="2006-01-02")`, "07/15/1985", "1985-07-15", false},
+		{`dateFormat("January 2, 2006")`, "1985-07-15", "July 15, 1985", false},
+		{"dateFormat(RFC1123)", "1985-07-15", "Mon, 15 Jul 1985 00:00:00 UTC", false},
+		{"dateFormat", "1985-07-15", "", true},
+		{"rfc3339", "1985-07-15", "1985-07-15T00:00:00Z", false},
+		{`rfc3339(from=DateTime, zone="America/Chicago")`, "2025-01-15 08:30:00", "2025-01-15T08:30:00-06:00", false},
+		{`rfc3339(zone="Mars/Olympus_Mons")`, "1985-07-15", "", true},
+		{"unixTime", "1985-07-15", "490233600", false},
+		{"unixTime(unit=milliseconds)", "1985-07-15", "490233600000", false},
+		{"unixTime(unit=minutes)", "1985-07-15", "", true},
+		{`timeZone(to="America/New_York", from=RFC3339)`, "2025-01-15T17:00:00Z", "2025-01-15T12:00:00-05:00", false},
+		{`timeZone("Asia/Tokyo", Kitchen, from=DateTime)`, "2025-01-15 17:00:00", "2:00AM", false},
+		{"timeZone", "2025-01-15T17:00:00Z", "", true},
+		{"datePart(year)", "1985-07-15", "1985", false},
+		{"datePart(quarter)", "1985-07-15", "3", false},
+		{"datePart(month)", "1985-07-15", "7", false},
+		{"datePart(monthName)", "1985-07-15", "July", false},
+		{"datePart(day)", "1985-07-15", "15", false},
+		{"datePart(dayOfYear)", "1985-07-15", "196", false},
+		{"datePart(weekday)", "1985-07-15", "Monday", false},
+		{"datePart(part=hour, from=RFC3339)", "1985-07-15T09:41:00Z", "9", false},
+		{"datePart(century)", "1985-07-15", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.transform+" "+test.input, func(t *testing.T) {
+			symbol, err := ParseFindAndReplaceSymbol("<Value transform=" + test.transform + ">")
+
+			if err != nil {
+				t.Fatalf("Got error %v parsing the transformation", err)
+			}
+
+			transformation := symbol.Modifiers.Transformations()[0]
+			fn, ok := LookupTransform(transformation.Name)
+
+			if !ok {
+				t.Fatalf("Transformation %s isn't registered", transformation.Name)
+			}
+
+			args := transformation.Args
+			args.AsOf = asOf
+			got, err := fn(test.input, args)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Got %s, wanted an error", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %v, wanted %s", err, test.want)
+			}
+
+			if got != test.want {
+				t.Errorf("Got %s, wanted %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewConverterUnknownTransform(t *testing.T) {
+
+	var tests = []struct {
+		name     string
+		template string
+	}{
+		{"alone", `<Patients.Patient.Name transform=testUnknown>`},
+		{"pipeline", `<Patients.Patient.Name transform=upper|testUnknown>`},
+		{"embedded", `Dr. <Patients.Patient.Name transform=trim transform=testUnknown>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewConverter(strings.NewReader(`{"Patients": [{"name": ` + strconv.Quote(test.template) + `}]}`))
+
+			var configError *ConfigError
+
+			if !errors.As(err, &configError) {
+				t.Fatalf("Got error %v, wanted a *ConfigError", err)
+			}
+
+			if !strings.Contains(err.Error(), "testUnknown") {
+				t.Errorf("Got error %v, wanted it to name the unknown transformation", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,484 @@
+// -----------------------------------------------------------------------------
+// File     : validate.go
+// Engineer : Christian Westbrook
+// Abstract :
+// This file contains the validation of configuration files. Where
+// NewConverter stops at the first problem that keeps a configuration file from
+// being used, ValidateConfig keeps going and reports every problem that it can
+// find along with the JSON path at which it was found, so that a configuration
+// file can be fixed in one pass.
+//
+// Example:
+// diagnostics, err := gopherhole.ValidateConfig(configReader)
+// for _, d := range diagnostics { fmt.Println(d) }
+// -----------------------------------------------------------------------------
+
+package gopherhole
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Package level variables
+var jsonPathKeyRegex = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`) // Keys that can be written after a '.' in a JSON path
+var symbolStartRegex = regexp.MustCompile(`<[a-zA-Z_]`)                 // The start of something that looks like a find and replace symbol
+
+// -----------------------------------------------------------------------------
+// VALIDATION
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Type     : Diagnostic
+//
+// Abstract :
+// A Diagnostic describes a single problem found in a configuration file, along
+// with the JSON path of the value that the problem was found in. Paths begin at
+// the root of the configuration file, written as $.
+//
+// Example: For an unknown transformation in the name field of the Patients
+// collection's definition, Path is '$.Patients[0].name'
+// -----------------------------------------------------------------------------
+type Diagnostic struct {
+	Path    string // The JSON path of the value that the problem was found in
+	Message string // A description of the problem
+}
+
+// -----------------------------------------------------------------------------
+// Method       : Diagnostic.String()
+// Input        : none
+// Output       : A string describing the problem and where it was found
+// Side Effects : none
+//
+// Abstract :
+// This method formats a Diagnostic as its path followed by its message.
+// -----------------------------------------------------------------------------
+func (d Diagnostic) String() string {
+	return d.Path + ": " + d.Message
+}
+
+// -----------------------------------------------------------------------------
+// Function     : ValidateConfig()
+// Input        :
+// config - A reader supplying a configuration file
+//
+// Output       :
+// diagnostics - A list of every problem found in the configuration file, in
+// the order of the configuration's collections, or an empty list if it's valid
+// err - A *ConfigError if the configuration file couldn't be read
+//
+// Side Effects : The input reader is read to completion
+//
+// Abstract :
+// This function checks that a configuration file is a JSON object mapping
+// each collection key to a list containing a single object definition, that
+// each find and replace symbol within those definitions can be parsed, that
+// every transformation they name is registered, and that every symbol and
+// $repeat key names an xmlKey within its collection.
+//
+// Transformations are looked up when ValidateConfig is called, so custom
+// transformations should be registered beforehand.
+// -----------------------------------------------------------------------------
+func ValidateConfig(config io.Reader) ([]Diagnostic, error) {
+
+	rawConfigInput, err := io.ReadAll(config)
+
+	if err != nil {
+		return nil, &ConfigError{Err: fmt.Errorf("error reading the config file: %w", err)}
+	}
+
+	configValue, err := unmarshalOrdered(rawConfigInput)
+
+	var syntaxError *json.SyntaxError
+
+	if errors.As(err, &syntaxError) {
+		// The offset counts the bytes read up to and including the problem
+		line, column := textPosition(rawConfigInput, max(syntaxError.Offset-1, 0))
+		return []Diagnostic{{Path: "$", Message: fmt.Sprintf("invalid JSON on line %d, column %d: %v", line, column, err)}}, nil
+	}
+
+	if err != nil {
+		return []Diagnostic{{Path: "$", Message: fmt.Sprintf("invalid JSON: %v", err)}}, nil
+	}
+
+	configObject, ok := configValue.(*orderedMap)
+
+	if !ok {
+		return []Diagnostic{{Path: "$", Message: "expected an object mapping collection keys to object definitions"}}, nil
+	}
+
+	return validateConfigObject(configObject), nil
+}
+
+// -----------------------------------------------------------------------------
+// Function     : validateConfigObject()
+// Input        : configObject - The parsed configuration file
+// Output       :
+// A list of every problem found in the configuration file, in the order of the
+// configuration's collections, or an empty list if it's valid
+//
+// Side Effects : none
+//
+// Abstract :
+// This function runs the checks described by ValidateConfig() on a
+// configuration file that has already been parsed. NewConverter() runs the
+// same checks, so that any configuration file that it accepts also passes
+// validation.
+// -----------------------------------------------------------------------------
+func validateConfigObject(configObject *orderedMap) []Diagnostic {
+
+	v := &validator{diagnostics: []Diagnostic{}}
+
+	// Namespace prefixes apply to every collection, wherever they're declared
+	declarations, ok := configObject.values["$namespaces"]
+
+	if ok {
+		v.namespaces(declarations, jsonPath("$", "$namespaces"))
+	}
+
+	if len(configObject.keys) == 0 || (ok && len(configObject.keys) == 1) {
+		v.add("$", "no collections are defined")
+	}
+
+	for _, k := range configObject.keys {
+		path := jsonPath("$", k)
+
+		if k == "$namespaces" {
+			continue
+		}
+
+		if !elementKeyRegex.MatchString(k) {
+			v.add(path, "invalid collection key '%s', expected a dotted path of XML element names", k)
+			continue
+		}
+
+		v.prefixes(k, path)
+
+		collection, ok := configObject.values[k].([]interface{})
+
+		if !ok || len(collection) == 0 {
+			v.add(path, "expected a list containing an object definition")
+			continue
+		}
+
+		if len(collection) > 1 {
+			v.add(path, "expected a single object definition, found %d", len(collection))
+		}
+
+		definitionPath := fmt.Sprintf("%s[0]", path)
+
+		if _, ok := collection[0].(*orderedMap); !ok {
+			v.add(definitionPath, "expected an object definition")
+		}
+
+		v.definition(collection[0], definitionPath, k)
+	}
+
+	return v.diagnostics
+}
+
+// -----------------------------------------------------------------------------
+// Type     : validator
+//
+// Abstract :
+// A validator gathers the problems found while walking a configuration file,
+// along with the namespace prefixes that the configuration file declares.
+// -----------------------------------------------------------------------------
+type validator struct {
+	declared    map[string]bool // The namespace prefixes declared under $namespaces
+	diagnostics []Diagnostic    // The problems found so far
+}
+
+// -----------------------------------------------------------------------------
+// Method       : validator.add()
+// Input        :
+// path - A string representing the JSON path of the value with a problem
+// format - A format string describing the problem, followed by its arguments
+//
+// Output       : none
+// Side Effects : The problem is added to the validator's diagnostics
+//
+// Abstract :
+// This method records a problem found in the configuration file.
+// -----------------------------------------------------------------------------
+func (v *validator) add(path string, format string, a ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+// -----------------------------------------------------------------------------
+// Method       : validator.namespaces()
+// Input        :
+// declarations - The typeless value of the configuration file's $namespaces key
+// path - A string representing the JSON path of the $namespaces key
+//
+// Output       : none
+// Side Effects : The declared prefixes are recorded
+//
+// Abstract :
+// This method checks that $namespaces maps each namespace prefix to a
+// different URI, and records the prefixes that are declared.
+// -----------------------------------------------------------------------------
+func (v *validator) namespaces(declarations interface{}, path string) {
+
+	v.declared = make(map[string]bool)
+	object, ok := declarations.(*orderedMap)
+
+	if !ok {
+		v.add(path, "expected an object mapping each namespace prefix to a URI")
+		return
+	}
+
+	prefixesByURI := make(map[string]string, len(object.keys))
+
+	for _, prefix := range object.keys {
+		prefixPath := jsonPath(path, prefix)
+		uri, ok := object.values[prefix].(string)
+
+		switch {
+		case !prefixRegex.MatchString(prefix):
+			v.add(prefixPath, "invalid namespace prefix '%s'", prefix)
+		case !ok || uri == "":
+			v.add(prefixPath, "expected the URI that the prefix stands for")
+		case prefixesByURI[uri] != "":
+			v.add(prefixPath, "the namespace prefixes '%s' and '%s' both stand for %s", prefixesByURI[uri], prefix, uri)
+		default:
+			prefixesByURI[uri] = prefix
+		}
+
+		v.declared[prefix] = true
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : validator.prefixes()
+// Input        :
+// name - A string representing an xmlKey named by the configuration file
+// path - A string representing the JSON path of the value naming the xmlKey
+//
+// Output       : none
+// Side Effects : Undeclared namespace prefixes are recorded as problems
+//
+// Abstract :
+// This method checks that every namespace prefix used by an xmlKey is
+// declared under $namespaces.
+// -----------------------------------------------------------------------------
+func (v *validator) prefixes(name string, path string) {
+
+	for _, segment := range strings.Split(name, ".") {
+		prefix, _, ok := strings.Cut(strings.TrimPrefix(segment, "@"), ":")
+
+		if ok && !v.declared[prefix] {
+			v.add(path, "%s uses the namespace prefix '%s', which isn't declared in $namespaces", name, prefix)
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : validator.definition()
+// Input        :
+// definition - A typeless value taken from the configuration file that
+// represents the definition of an object, or a part of one
+// path - A string representing the JSON path of the definition
+// collection - A string representing the collection key of the definition
+//
+// Output       : none
+// Side Effects : Any problems found are recorded
+//
+// Abstract :
+// This method walks an object definition, recursing into nested objects and
+// arrays, and checks the find and replace symbols and $repeat keys within it.
+// -----------------------------------------------------------------------------
+func (v *validator) definition(definition interface{}, path string, collection string) {
+
+	switch d := definition.(type) {
+	case *orderedMap:
+		for _, k := range d.keys {
+			if k == "$repeat" {
+				v.repeat(d.values[k], jsonPath(path, k), collection)
+				continue
+			}
+
+			v.definition(d.values[k], jsonPath(path, k), collection)
+		}
+	case []interface{}:
+		for i, value := range d {
+			v.definition(value, fmt.Sprintf("%s[%d]", path, i), collection)
+		}
+	case string:
+		v.template(d, path, collection)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Method       : validator.repeat()
+// Input        :
+// repeat - The typeless value of a $repeat key
+// path - A string representing the JSON path of the $repeat key
+// collection - A string representing the collection key of the definition
+//
+// Output       : none
+// Side Effects : Any problems found are recorded
+//
+// Abstract :
+// This method checks that a $repeat key names an xmlKey within its
+// collection's objects.
+// -----------------------------------------------------------------------------
+func (v *validator) repeat(repeat interface{}, path string, collection string) {
+
+	name, ok := repeat.(string)
+
+	if !ok || !elementKeyRegex.MatchString(name) {
+		v.add(path, "expected the dotted xmlKey of the repeated element")
+		return
+	}
+
+	if !strings.HasPrefix(name, collection+".") {
+		v.add(path, "%s isn't within the collection %s", name, collection)
+		return
+	}
+
+	v.prefixes(name, path)
+}
+
+// -----------------------------------------------------------------------------
+// Method       : validator.template()
+// Input        :
+// template - A string taken from an object definition
+// path - A string representing the JSON path of the string
+// collection - A string representing the collection key of the definition
+//
+// Output       : none
+// Side Effects : Any problems found are recorded
+//
+// Abstract :
+// This method checks each find and replace symbol within a string, as
+// matched by FindAndReplaceExpression. Each symbol must parse, name only
+// registered transformations, and name an xmlKey within its collection. Text
+// that looks like the start of a symbol but is never closed is reported too.
+// -----------------------------------------------------------------------------
+func (v *validator) template(template string, path string, collection string) {
+
+	matched := findAndReplaceRegex.FindAllStringIndex(template, -1)
+
+	for _, location := range matched {
+		text := template[location[0]:location[1]]
+		symbol, err := ParseFindAndReplaceSymbol(text)
+
+		if err != nil {
+			v.add(path, "%v", err)
+			continue
+		}
+
+		for _, transformation := range symbol.Modifiers.Transformations() {
+			if _, ok := LookupTransform(transformation.Name); !ok {
+				v.add(path, "unknown transformation '%s' in %s", transformation.Name, text)
+			}
+		}
+
+		if !strings.HasPrefix(symbol.Name, collection+".") {
+			v.add(path, "%s isn't within the collection %s", text, collection)
+			continue
+		}
+
+		v.prefixes(symbol.Name, path)
+	}
+
+	// Look for symbols that were opened but never matched, e.g. a missing '>'
+	// or an unterminated quote, which would otherwise be copied into the
+	// output as they are
+	for _, location := range symbolStartRegex.FindAllStringIndex(template, -1) {
+		inside := false
+
+		for _, m := range matched {
+			if location[0] >= m[0] && location[0] < m[1] {
+				inside = true
+				break
+			}
+		}
+
+		if inside {
+			continue
+		}
+
+		// The symbol runs to the next '>', if there is one
+		text := template[location[0]:]
+		end := strings.Index(text, ">")
+
+		if end >= 0 {
+			text = text[:end+1]
+		}
+
+		_, err := ParseFindAndReplaceSymbol(text)
+
+		if err == nil {
+			err = fmt.Errorf("malformed find and replace symbol %s", text)
+		}
+
+		v.add(path, "%v", err)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// UTILITY
+// -----------------------------------------------------------------------------
+
+// -----------------------------------------------------------------------------
+// Function     : jsonPath()
+// Input        :
+// parent - A string representing the JSON path of an object
+// key - A string representing a key within that object
+//
+// Output       : A string representing the JSON path of the key's value
+// Side Effects : none
+//
+// Abstract :
+// This function extends a JSON path with an object key. Keys that aren't
+// simple identifiers, such as collection keys containing dots, are written in
+// brackets.
+//
+// Example: jsonPath("$", "Hospital.Patients") is '$["Hospital.Patients"]'
+// -----------------------------------------------------------------------------
+func jsonPath(parent string, key string) string {
+
+	if jsonPathKeyRegex.MatchString(key) {
+		return parent + "." + key
+	}
+
+	quoted, _ := json.Marshal(key)
+
+	return parent + "[" + string(quoted) + "]"
+}
+
+// -----------------------------------------------------------------------------
+// Function     : textPosition()
+// Input        :
+// text - The text of a file
+// offset - The byte offset of a position within the file
+//
+// Output       :
+// line - The line of the position, counted from 1
+// column - The column of the position, counted from 1
+//
+// Side Effects : none
+//
+// Abstract :
+// This function converts a byte offset into a line and column.
+// -----------------------------------------------------------------------------
+func textPosition(text []byte, offset int64) (int, int) {
+
+	offset = min(offset, int64(len(text)))
+	before := text[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
+
+// -----------------------------------------------------------------------------
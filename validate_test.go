@@ -0,0 +1,82 @@
+package gopherhole
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+
+	var tests = []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{"valid", `{"Patients": [{"name": "<Patients.Patient.Name transform=trim|upper>", "phones": [{"$repeat": "Patients.Patient.Phone", "number": "<Patients.Patient.Phone>"}]}]}`, []string{}},
+		{"invalid JSON", "{\n  \"Patients\": [\n}", []string{"$: invalid JSON on line 3, column 1: invalid character '}' looking for beginning of value"}},
+		{"not an object", `["Patients"]`, []string{"$: expected an object mapping collection keys to object definitions"}},
+		{"empty", `{}`, []string{"$: no collections are defined"}},
+		{"missing definition", `{"Patients": {}, "Staff": []}`, []string{
+			"$.Patients: expected a list containing an object definition",
+			"$.Staff: expected a list containing an object definition",
+		}},
+		{"invalid collection key", `{"Patients..Patient": [{}]}`, []string{`$["Patients..Patient"]: invalid collection key 'Patients..Patient', expected a dotted path of XML element names`}},
+		{"several definitions", `{"Patients": ["<Patients.Patient.Name>", {}]}`, []string{
+			"$.Patients: expected a single object definition, found 2",
+			"$.Patients[0]: expected an object definition",
+		}},
+		{"unknown modifier", `{"Patients": [{"name": "<Patients.Patient.Name colour=red>"}]}`, []string{
+			"$.Patients[0].name: invalid find and replace symbol <Patients.Patient.Name colour=red>: unknown modifier 'colour' at position 23",
+		}},
+		{"unknown transformation", `{"Hospital.Patients": [{"ids": ["<Hospital.Patients.Patient.ID transform=trim|shout>"]}]}`, []string{
+			`$["Hospital.Patients"][0].ids[0]: unknown transformation 'shout' in <Hospital.Patients.Patient.ID transform=trim|shout>`,
+		}},
+		{"wrong collection", `{"Patients": [{"name": "Dr. <Staff.Doctor.Name>", "visits": {"$repeat": "Visits.Visit"}}]}`, []string{
+			"$.Patients[0].name: <Staff.Doctor.Name> isn't within the collection Patients",
+			"$.Patients[0].visits.$repeat: Visits.Visit isn't within the collection Patients",
+		}},
+		{"namespaces", `{"$namespaces": {"p": "urn:example:patients"}, "p:Patients": [{"mrn": "<p:Patients.p:Patient.ext:MRN>", "ids": {"$repeat": "p:Patients.p:Patient.x:ID"}}]}`, []string{
+			"$[\"p:Patients\"][0].mrn: p:Patients.p:Patient.ext:MRN uses the namespace prefix 'ext', which isn't declared in $namespaces",
+			"$[\"p:Patients\"][0].ids.$repeat: p:Patients.p:Patient.x:ID uses the namespace prefix 'x', which isn't declared in $namespaces",
+		}},
+		{"invalid namespaces", `{"$namespaces": {"p": "urn:a", "q": "urn:a", "r": "", "1x": "urn:b"}, "q:Patients": [{}]}`, []string{
+			`$.$namespaces.q: the namespace prefixes 'p' and 'q' both stand for urn:a`,
+			`$.$namespaces.r: expected the URI that the prefix stands for`,
+			`$.$namespaces["1x"]: invalid namespace prefix '1x'`,
+		}},
+		{"only namespaces", `{"$namespaces": []}`, []string{
+			"$.$namespaces: expected an object mapping each namespace prefix to a URI",
+			"$: no collections are defined",
+		}},
+		{"attribute collection key", `{"Patients.@Facility": [{}]}`, []string{
+			`$["Patients.@Facility"]: invalid collection key 'Patients.@Facility', expected a dotted path of XML element names`,
+		}},
+		{"attribute prefixes", `{"Patients": [{"type": "<Patients.Patient.Phone.@x:Type>", "phones": {"$repeat": "Patients.Patient.@Phone"}}]}`, []string{
+			"$.Patients[0].type: Patients.Patient.Phone.@x:Type uses the namespace prefix 'x', which isn't declared in $namespaces",
+			"$.Patients[0].phones.$repeat: expected the dotted xmlKey of the repeated element",
+		}},
+		{"unterminated symbol", `{"Patients": [{"name": "<Patients.Patient.Name"}]}`, []string{"$.Patients[0].name: invalid find and replace symbol <Patients.Patient.Name: expected '>' at position 22"}},
+		{"unterminated quote", `{"Patients": [{"name": "<Patients.Patient.Name default=\"x>"}]}`, []string{`$.Patients[0].name: invalid find and replace symbol <Patients.Patient.Name default="x>: unterminated quoted string at position 31`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diagnostics, err := ValidateConfig(strings.NewReader(test.config))
+
+			if err != nil {
+				t.Fatalf("Got error %v validating the config", err)
+			}
+
+			got := []string{}
+
+			for _, diagnostic := range diagnostics {
+				got = append(got, diagnostic.String())
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Got %q, wanted %q", got, test.want)
+			}
+		})
+	}
+}